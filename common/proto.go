@@ -0,0 +1,64 @@
+package common
+
+// Protobuf wire types.
+const (
+	ProtoVarint          = 0
+	ProtoFixed64         = 1
+	ProtoLengthDelimited = 2
+	ProtoFixed32         = 5
+)
+
+// ReadProtoVarint reads a varint starting at the offset of the binary protobuf
+// message. It returns the value and the offset right after it or -1 if the
+// varint is malformed.
+func ReadProtoVarint(msg []byte, offset int) (int, int) {
+	var value, shift int
+
+	for i := offset; i < len(msg) && i < offset+10; i++ {
+		b := int(msg[i])
+		value |= (b & 0x7F) << shift
+		if b < 0x80 {
+			return value, i + 1
+		}
+		shift += 7
+	}
+
+	return 0, -1
+}
+
+// ReadProtoField reads a field starting at the offset of the binary protobuf
+// message. It returns the field number, the wire type and the boundaries of
+// the field value. For length-delimited fields the value is a payload without
+// the length prefix. The end boundary is the offset of the next field or -1
+// if the field is malformed.
+func ReadProtoField(msg []byte, offset int) (int, int, int, int) {
+	tag, start := ReadProtoVarint(msg, offset)
+	if start < 0 {
+		return 0, 0, 0, -1
+	}
+
+	num := tag >> 3
+	typ := tag & 7
+	end := -1
+
+	switch typ {
+	case ProtoVarint:
+		_, end = ReadProtoVarint(msg, start)
+	case ProtoFixed64:
+		end = start + 8
+	case ProtoLengthDelimited:
+		var ln int
+		ln, start = ReadProtoVarint(msg, start)
+		if start >= 0 {
+			end = start + ln
+		}
+	case ProtoFixed32:
+		end = start + 4
+	}
+
+	if num == 0 || end > len(msg) {
+		end = -1
+	}
+
+	return num, typ, start, end
+}
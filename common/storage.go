@@ -1,6 +1,7 @@
 package common
 
 import (
+	"github.com/nspcc-dev/neo-go/pkg/interop/convert"
 	"github.com/nspcc-dev/neo-go/pkg/interop/native/std"
 	"github.com/nspcc-dev/neo-go/pkg/interop/storage"
 )
@@ -19,3 +20,27 @@ func SetSerialized(ctx storage.Context, key interface{}, value interface{}) {
 	data := std.Serialize(value)
 	storage.Put(ctx, key, data)
 }
+
+// EpochKeySize is the size of the epoch number representation returned by EpochKey.
+const EpochKeySize = 8
+
+// EpochKey returns a fixed size big-endian representation of the epoch number.
+// Storage items with such key postfixes are iterated in ascending epoch order.
+func EpochKey(epoch int) []byte {
+	le := convert.ToBytes(epoch)
+	key := make([]byte, EpochKeySize)
+	for i := 0; i < len(le) && i < EpochKeySize; i++ {
+		key[EpochKeySize-1-i] = le[i]
+	}
+	return key
+}
+
+// EpochFromKey returns the epoch number from its representation returned by
+// EpochKey.
+func EpochFromKey(key []byte) int {
+	epoch := 0
+	for i := 0; i < EpochKeySize; i++ {
+		epoch = epoch*256 + int(key[i])
+	}
+	return epoch
+}
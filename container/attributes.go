@@ -0,0 +1,157 @@
+package container
+
+import (
+	"github.com/nspcc-dev/neo-go/pkg/interop/iterator"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/crypto"
+	"github.com/nspcc-dev/neo-go/pkg/interop/storage"
+	"github.com/nspcc-dev/neofs-contract/common"
+)
+
+// containerAttribute contains container.Attribute fields.
+type containerAttribute struct {
+	key   string
+	value string
+}
+
+// V2 format
+const (
+	containerAttributesField = 5
+
+	attributeKeyField   = 1
+	attributeValueField = 2
+)
+
+const (
+	// Attribute index keys are prefix + ripemd160(key + "\x00" + value) +
+	// container ID.
+	attributeIndexPrefix = "attrCnr_"
+	// attributeIndexKey is set when the attribute index contains all the
+	// containers.
+	attributeIndexKey = "attributeIndex"
+)
+
+// ContainersByAttribute method returns an iterator over IDs of the containers
+// which have an attribute with the specified key and value, e.g. `Name` and
+// `my-container`.
+func ContainersByAttribute(key, value string) iterator.Iterator {
+	ctx := storage.GetReadOnlyContext()
+	prefix := append([]byte(attributeIndexPrefix), attributeID(key, value)...)
+	return storage.Find(ctx, prefix, storage.ValuesOnly)
+}
+
+// Attributes method returns a map of the container attributes decoded from
+// the stable marshaled Container structure.
+//
+// If the container doesn't exist, it panics with NotFoundError.
+func Attributes(containerID []byte) map[string]string {
+	ctx := storage.GetReadOnlyContext()
+
+	cnt := getContainer(ctx, containerID)
+	if len(cnt.value) == 0 {
+		panic(NotFoundError)
+	}
+
+	attrs := parseContainerAttributes(cnt.value)
+
+	res := map[string]string{}
+	for i := range attrs {
+		res[attrs[i].key] = attrs[i].value
+	}
+
+	return res
+}
+
+// parseContainerAttributes returns attributes of a stable marshaled Container
+// structure. Containers are validated by the Inner Ring, so the parsing stops
+// at the first malformed field and malformed attributes are skipped instead
+// of panicking.
+func parseContainerAttributes(data []byte) []containerAttribute {
+	var (
+		attrs = []containerAttribute{}
+		start int
+		end   int
+	)
+
+	for offset := 0; offset < len(data); offset = end {
+		var num, typ int
+
+		num, typ, start, end = common.ReadProtoField(data, offset)
+		if end < 0 {
+			break
+		}
+
+		if num == containerAttributesField && typ == common.ProtoLengthDelimited {
+			attr := parseContainerAttribute(data[start:end])
+			if len(attr.key) != 0 && len(attr.value) != 0 {
+				attrs = append(attrs, attr)
+			}
+		}
+	}
+
+	return attrs
+}
+
+// parseContainerAttribute parses a stable marshaled container.Attribute
+// structure. Key and value are empty if the structure is malformed.
+func parseContainerAttribute(data []byte) containerAttribute {
+	var (
+		attr  containerAttribute
+		start int
+		end   int
+	)
+
+	for offset := 0; offset < len(data); offset = end {
+		var num, typ int
+
+		num, typ, start, end = common.ReadProtoField(data, offset)
+		if end < 0 || typ != common.ProtoLengthDelimited {
+			return containerAttribute{}
+		}
+
+		switch num {
+		case attributeKeyField:
+			attr.key = string(data[start:end])
+		case attributeValueField:
+			attr.value = string(data[start:end])
+		}
+	}
+
+	return attr
+}
+
+func attributeID(key, value string) []byte {
+	return crypto.Ripemd160([]byte(key + "\x00" + value))
+}
+
+// indexAttributes adds the container to the attribute index.
+func indexAttributes(ctx storage.Context, id []byte, attrs []containerAttribute) {
+	for i := range attrs {
+		indexKey := append([]byte(attributeIndexPrefix), attributeID(attrs[i].key, attrs[i].value)...)
+		storage.Put(ctx, append(indexKey, id...), id)
+	}
+}
+
+// unindexAttributes removes the container from the attribute index.
+func unindexAttributes(ctx storage.Context, id []byte, attrs []containerAttribute) {
+	for i := range attrs {
+		indexKey := append([]byte(attributeIndexPrefix), attributeID(attrs[i].key, attrs[i].value)...)
+		storage.Delete(ctx, append(indexKey, id...))
+	}
+}
+
+// migrateAttributeIndex builds the attribute index from the containers
+// stored before it was supported.
+func migrateAttributeIndex(ctx storage.Context) {
+	if storage.Get(ctx, attributeIndexKey) != nil {
+		return
+	}
+
+	it := storage.Find(ctx, []byte(containerIndexPrefix), storage.ValuesOnly)
+	for iterator.Next(it) {
+		id := iterator.Value(it).([]byte)
+		cnt := getContainer(ctx, id)
+		indexAttributes(ctx, id, parseContainerAttributes(cnt.value))
+	}
+
+	storage.Put(ctx, attributeIndexKey, true)
+}
@@ -41,6 +41,47 @@ type (
 		cid         []byte
 		estimations []estimation
 	}
+
+	// sizeEstimation is a container size estimation passed to
+	// PutContainerSizes method.
+	sizeEstimation struct {
+		cid  []byte
+		size int
+	}
+
+	// containerUsage is an aggregate of the container size estimations
+	// of an epoch.
+	containerUsage struct {
+		median    int
+		mean      int
+		reporters int
+	}
+
+	// ownerUsage is a sum of the aggregated container sizes of the owner
+	// in an epoch.
+	ownerUsage struct {
+		epoch int
+		size  int
+	}
+
+	// eACLVersion is an extended ACL of the container set in the specified
+	// epoch. Empty value means that the extended ACL has been removed.
+	eACLVersion struct {
+		epoch int
+		eACL  ExtendedACL
+	}
+
+	containerStatus int
+
+	// ownershipTransfer is a record of the container ownership change
+	// approved by the Alphabet.
+	ownershipTransfer struct {
+		from  []byte
+		to    []byte
+		sig   interop.Signature
+		pub   interop.PublicKey
+		token []byte
+	}
 )
 
 const (
@@ -56,22 +97,66 @@ const (
 	RegistrationFeeKey = "ContainerFee"
 	// AliasFeeKey is a key in netmap config which contains fee for nice-name registration.
 	AliasFeeKey = "ContainerAliasFee"
+	// RemovalGracePeriodKey is a key in netmap config which contains the number
+	// of epochs during which the metadata of a removed container is kept.
+	RemovalGracePeriodKey = "ContainerRemovalGracePeriod"
+	// OwnerUsageRetentionKey is a key in netmap config which contains the number
+	// of the last epochs for which owner usage totals are kept. UsageCleanupDelta
+	// is used if it is not set.
+	OwnerUsageRetentionKey = "ContainerOwnerUsageRetention"
 
 	// V2 format
 	containerIDSize = 32 // SHA256 size
+	ownerIDSize     = 25
+
+	// Keys of the container index and counters must not be 32 bytes long
+	// or start with owner ID prefix, so they are not mixed up with
+	// containers and owner index.
+	containerCountKey    = "containerCount"
+	ownerCountPrefix     = "ownerCount_"
+	containerIndexPrefix = "containers_"
+	ownerOverridePrefix  = "ownerOf_"
+	ownerHistoryPrefix   = "ownerHistory_"
+
+	// Removed container keys are prefix + ID, purge queue keys are
+	// prefix + epoch + ID.
+	removedPrefix = "removed_"
+	purgePrefix   = "purge_"
+
+	// Extended ACL history keys are prefix + container ID + epoch.
+	eACLHistoryPrefix = "aclHistory_"
+
+	// Aggregated container usage keys are prefix + epoch + container ID.
+	usagePrefix = "usage_"
+	// Owner usage keys are prefix + owner ID + epoch, owner usage epoch index
+	// keys are prefix + epoch + owner ID.
+	ownerUsagePrefix      = "ownerUsage_"
+	ownerUsageEpochPrefix = "ownerUsageEpoch_"
 
 	singleEstimatePrefix = "est"
 	estimateKeyPrefix    = "cnr"
 	estimatePostfixSize  = 10
+	// Size index keys are prefix + epoch + container ID + size + estimation
+	// key postfix, so the estimations of the container are iterated in
+	// ascending size order.
+	sizeIndexPrefix = "sz"
 	// CleanupDelta contains the number of the last epochs for which container estimations are present.
 	CleanupDelta = 3
 	// TotalCleanupDelta contains the number of the epochs after which estimation
 	// will be removed by epoch tick cleanup if any of the nodes hasn't updated
 	// container size and/or container has been removed. It must be greater than CleanupDelta.
 	TotalCleanupDelta = CleanupDelta + 1
+	// UsageCleanupDelta contains the number of the last epochs for which
+	// aggregated container usage is present. It must be greater than
+	// TotalCleanupDelta.
+	UsageCleanupDelta = 100
 
 	// NotFoundError is returned if container is missing.
 	NotFoundError = "container does not exist"
+	// RemovedError is returned if container has been removed.
+	RemovedError = "container has been removed"
+	// AlreadyExistsError is returned if container with the same ID exists.
+	AlreadyExistsError = "container already exists"
 
 	// default SOA record field values
 	defaultRefresh = 3600   // 1 hour
@@ -80,6 +165,15 @@ const (
 	defaultTTL     = 3600   // 1 hour
 )
 
+// Container statuses returned by Status method. Removed containers keep
+// their metadata during the grace period, but their IDs are never available
+// again.
+const (
+	NotFoundStatus containerStatus = iota
+	LiveStatus
+	RemovedStatus
+)
+
 var (
 	eACLPrefix = []byte("eACL")
 )
@@ -93,6 +187,10 @@ func _deploy(data interface{}, isUpdate bool) {
 	if isUpdate {
 		args := data.([]interface{})
 		common.CheckVersion(args[len(args)-1].(int))
+		migrateContainerIndex(ctx)
+		migrateEACLHistory(ctx)
+		migrateAttributeIndex(ctx)
+		migrateSizeIndex(ctx)
 		return
 	}
 
@@ -116,6 +214,8 @@ func _deploy(data interface{}, isUpdate bool) {
 	storage.Put(ctx, neofsIDContractKey, args.addrID)
 	storage.Put(ctx, nnsContractKey, args.addrNNS)
 	storage.Put(ctx, nnsRootKey, args.nnsRoot)
+	storage.Put(ctx, containerCountKey, 0)
+	storage.Put(ctx, attributeIndexKey, true)
 
 	// initialize the way to collect signatures
 	storage.Put(ctx, notaryDisabledKey, args.notaryDisabled)
@@ -165,6 +265,9 @@ func Update(script []byte, manifest []byte, data interface{}) {
 // PublicKey contains the public key of the signer.
 // Token is optional and should be a stable marshaled SessionToken structure from
 // API.
+//
+// If the container with the same ID exists, it panics with AlreadyExistsError.
+// If it has been removed, it panics with RemovedError.
 func Put(container []byte, signature interop.Signature, publicKey interop.PublicKey, token []byte) {
 	PutNamed(container, signature, publicKey, token, "", "")
 }
@@ -179,6 +282,14 @@ func PutNamed(container []byte, signature interop.Signature,
 
 	ownerID := ownerFromBinaryContainer(container)
 	containerID := crypto.Sha256(container)
+	if isRemoved(ctx, containerID) {
+		panic(RemovedError)
+	}
+	if storage.Get(ctx, containerID) != nil {
+		panic(AlreadyExistsError)
+	}
+	attrs := parseContainerAttributes(container)
+
 	neofsIDContractAddr := storage.Get(ctx, neofsIDContractKey).(interop.Hash160)
 	cnr := Container{
 		value: container,
@@ -236,7 +347,6 @@ func PutNamed(container []byte, signature interop.Signature,
 		multiaddr := common.AlphabetAddress()
 		common.CheckAlphabetWitness(multiaddr)
 	}
-	// todo: check if new container with unique container id
 
 	details := common.ContainerFeeTransferDetails(containerID)
 
@@ -253,7 +363,7 @@ func PutNamed(container []byte, signature interop.Signature,
 		)
 	}
 
-	addContainer(ctx, containerID, ownerID, cnr)
+	addContainer(ctx, containerID, ownerID, cnr, attrs)
 
 	if name != "" {
 		if needRegister {
@@ -303,9 +413,13 @@ func checkNiceNameAvailable(nnsContractAddr interop.Hash160, domain string) bool
 	return false
 }
 
-// Delete method removes a container from the contract storage if it has been
-// invoked by Alphabet nodes of the Inner Ring. Otherwise, it produces
-// containerDelete notification.
+// Delete method removes a container if it has been invoked by Alphabet nodes
+// of the Inner Ring. Otherwise, it produces containerDelete notification.
+//
+// Removed container is not listed anymore, but its metadata is kept in the
+// contract storage for the number of epochs specified in
+// ContainerRemovalGracePeriod netmap config. Container ID can't be used again
+// after the removal.
 //
 // Signature is a RFC6979 signature of the container ID.
 // Token is optional and should be a stable marshaled SessionToken structure from
@@ -317,7 +431,7 @@ func Delete(containerID []byte, signature interop.Signature, token []byte) {
 	notaryDisabled := storage.Get(ctx, notaryDisabledKey).(bool)
 
 	ownerID := getOwnerByID(ctx, containerID)
-	if ownerID == nil {
+	if ownerID == nil || isRemoved(ctx, containerID) {
 		return
 	}
 
@@ -343,24 +457,302 @@ func Delete(containerID []byte, signature interop.Signature, token []byte) {
 		common.CheckAlphabetWitness(multiaddr)
 	}
 
-	key := append([]byte(nnsHasAliasKey), containerID...)
-	domain := storage.Get(ctx, key).(string)
-	if len(domain) != 0 {
-		storage.Delete(ctx, key)
-		// We should do `getRecord` first because NNS record could be deleted
-		// by other means (expiration, manual), thus leading to failing `deleteRecord`
-		// and inability to delete a container. We should also check if we own the record in case.
-		nnsContractAddr := storage.Get(ctx, nnsContractKey).(interop.Hash160)
-		res := contract.Call(nnsContractAddr, "getRecords", contract.ReadStates|contract.AllowCall, domain, 16 /* TXT */)
-		if res != nil && std.Base58Encode(containerID) == string(res.([]interface{})[0].(string)) {
-			contract.Call(nnsContractAddr, "deleteRecords", contract.All, domain, 16 /* TXT */)
-		}
+	deleteAlias(ctx, containerID)
+
+	netmapContractAddr := storage.Get(ctx, netmapContractKey).(interop.Hash160)
+	epoch := contract.Call(netmapContractAddr, "epoch", contract.ReadOnly).(int)
+	purgeEpoch := epoch
+	gracePeriod := contract.Call(netmapContractAddr, "config", contract.ReadOnly, RemovalGracePeriodKey)
+	if gracePeriod != nil {
+		purgeEpoch += gracePeriod.(int)
 	}
-	removeContainer(ctx, containerID, ownerID)
+
+	removeContainer(ctx, containerID, ownerID, epoch, purgeEpoch)
 	runtime.Log("remove container")
 	runtime.Notify("DeleteSuccess", containerID)
 }
 
+// TransferOwnership method changes the owner of the container if it has been
+// invoked by Alphabet nodes of the Inner Ring. Otherwise, it produces
+// containerTransferOwnership notification.
+//
+// NewOwner is a 25 byte Owner ID of the new container owner.
+// Signature is a RFC6979 signature of the container ID concatenated with
+// the new Owner ID made by the current container owner.
+// PublicKey contains the public key of the signer.
+// Token is optional and should be a stable marshaled SessionToken structure from
+// API.
+//
+// The original container structure is kept as is, so Owner method should be
+// used to get the actual owner. Approved transfers are available via
+// OwnershipHistory method.
+//
+// If the container doesn't exist, it panics with NotFoundError.
+func TransferOwnership(containerID, newOwner []byte, signature interop.Signature, publicKey interop.PublicKey, token []byte) {
+	ctx := storage.GetContext()
+	notaryDisabled := storage.Get(ctx, notaryDisabledKey).(bool)
+
+	if len(newOwner) != ownerIDSize {
+		panic("invalid owner")
+	}
+
+	ownerID := getOwnerByID(ctx, containerID)
+	if ownerID == nil {
+		panic(NotFoundError)
+	}
+
+	if isRemoved(ctx, containerID) {
+		panic(RemovedError)
+	}
+
+	if common.BytesEqual(ownerID, newOwner) {
+		panic("container is already owned by the specified owner")
+	}
+
+	if notaryDisabled {
+		alphabet := common.AlphabetNodes()
+		nodeKey := common.InnerRingInvoker(alphabet)
+		if len(nodeKey) == 0 {
+			runtime.Notify("containerTransferOwnership", containerID, newOwner, signature, publicKey, token)
+			return
+		}
+
+		threshold := len(alphabet)*2/3 + 1
+		id := common.InvokeID([]interface{}{containerID, newOwner, signature}, []byte("transferOwnership"))
+
+		n := common.Vote(ctx, id, nodeKey)
+		if n < threshold {
+			return
+		}
+
+		common.RemoveVotes(ctx, id)
+	} else {
+		multiaddr := common.AlphabetAddress()
+		common.CheckAlphabetWitness(multiaddr)
+	}
+
+	storage.Delete(ctx, append(ownerID, containerID...))
+	updateOwnerCount(ctx, ownerID, -1)
+
+	storage.Put(ctx, append(newOwner, containerID...), containerID)
+	updateOwnerCount(ctx, newOwner, 1)
+
+	storage.Put(ctx, append([]byte(ownerOverridePrefix), containerID...), newOwner)
+
+	history := getOwnershipHistory(ctx, containerID)
+	history = append(history, ownershipTransfer{
+		from:  ownerID,
+		to:    newOwner,
+		sig:   signature,
+		pub:   publicKey,
+		token: token,
+	})
+	common.SetSerialized(ctx, append([]byte(ownerHistoryPrefix), containerID...), history)
+
+	runtime.Log("container ownership transferred")
+	runtime.Notify("TransferOwnershipSuccess", containerID, ownerID, newOwner)
+}
+
+// OwnershipHistory method returns an array of structures that contain
+// the previous and the new Owner IDs, the signature, the public key of
+// the signer and a stable marshaled SessionToken structure if it was
+// provided for every ownership transfer of the container, oldest first.
+//
+// If the container doesn't exist, it panics with NotFoundError.
+func OwnershipHistory(containerID []byte) []ownershipTransfer {
+	ctx := storage.GetReadOnlyContext()
+
+	ownerID := getOwnerByID(ctx, containerID)
+	if ownerID == nil {
+		panic(NotFoundError)
+	}
+
+	return getOwnershipHistory(ctx, containerID)
+}
+
+// Status method returns the status of the container: 0 if the container has
+// never existed, 1 if it is live and 2 if it has been removed.
+func Status(containerID []byte) containerStatus {
+	ctx := storage.GetReadOnlyContext()
+
+	if len(containerID) != containerIDSize {
+		return NotFoundStatus
+	}
+
+	if isRemoved(ctx, containerID) {
+		return RemovedStatus
+	}
+
+	if storage.Get(ctx, containerID) != nil {
+		return LiveStatus
+	}
+
+	return NotFoundStatus
+}
+
+// SetAlias method sets a nice-name alias of the container in NNS contract if
+// it has been invoked by Alphabet nodes of the Inner Ring. Otherwise, it
+// produces setAlias notification. The previous alias of the container is
+// removed. The fee is the same as for the alias set by PutNamed method.
+// If the container already has the same alias, the method does nothing.
+//
+// Zone is the NNS root of container contract if empty and must exist.
+// Signature is a RFC6979 signature of the container ID concatenated with
+// the domain name.
+// PublicKey contains the public key of the signer.
+// Token is optional and should be a stable marshaled SessionToken structure from
+// API.
+//
+// If the container doesn't exist, it panics with NotFoundError.
+func SetAlias(containerID []byte, name, zone string, signature interop.Signature,
+	publicKey interop.PublicKey, token []byte) {
+	ctx := storage.GetContext()
+	notaryDisabled := storage.Get(ctx, notaryDisabledKey).(bool)
+
+	ownerID := getOwnerByID(ctx, containerID)
+	if ownerID == nil {
+		panic(NotFoundError)
+	}
+
+	if isRemoved(ctx, containerID) {
+		panic(RemovedError)
+	}
+
+	if name == "" {
+		panic("empty name")
+	}
+	if zone == "" {
+		zone = storage.Get(ctx, nnsRootKey).(string)
+	}
+	domain := name + "." + zone
+	aliasKey := append([]byte(nnsHasAliasKey), containerID...)
+	if storage.Get(ctx, aliasKey).(string) == domain {
+		return
+	}
+
+	nnsContractAddr := storage.Get(ctx, nnsContractKey).(interop.Hash160)
+	needRegister := checkNiceNameAvailable(nnsContractAddr, domain)
+
+	alphabet := common.AlphabetNodes()
+	from := common.WalletToScriptHash(ownerID)
+	netmapContractAddr := storage.Get(ctx, netmapContractKey).(interop.Hash160)
+	balanceContractAddr := storage.Get(ctx, balanceContractKey).(interop.Hash160)
+	aliasFee := contract.Call(netmapContractAddr, "config", contract.ReadOnly, AliasFeeKey).(int)
+	balance := contract.Call(balanceContractAddr, "balanceOf", contract.ReadOnly, from).(int)
+
+	if balance < aliasFee*len(alphabet) {
+		panic("insufficient balance to set alias")
+	}
+
+	if notaryDisabled {
+		nodeKey := common.InnerRingInvoker(alphabet)
+		if len(nodeKey) == 0 {
+			runtime.Notify("setAlias", containerID, name, zone, signature, publicKey, token)
+			return
+		}
+
+		threshold := len(alphabet)*2/3 + 1
+		id := common.InvokeID([]interface{}{containerID, name, zone, signature}, []byte("setAlias"))
+
+		n := common.Vote(ctx, id, nodeKey)
+		if n < threshold {
+			return
+		}
+
+		common.RemoveVotes(ctx, id)
+	} else {
+		multiaddr := common.AlphabetAddress()
+		common.CheckAlphabetWitness(multiaddr)
+	}
+
+	details := common.ContainerFeeTransferDetails(containerID)
+
+	for i := 0; i < len(alphabet); i++ {
+		node := alphabet[i]
+		to := contract.CreateStandardAccount(node)
+
+		contract.Call(balanceContractAddr, "transferX",
+			contract.All,
+			from,
+			to,
+			aliasFee,
+			details,
+		)
+	}
+
+	deleteAlias(ctx, containerID)
+
+	if needRegister {
+		res := contract.Call(nnsContractAddr, "register", contract.All,
+			domain, runtime.GetExecutingScriptHash(), "ops@nspcc.ru",
+			defaultRefresh, defaultRetry, defaultExpire, defaultTTL).(bool)
+		if !res {
+			panic("can't register the domain " + domain)
+		}
+	}
+	contract.Call(nnsContractAddr, "addRecord", contract.All,
+		domain, 16 /* TXT */, std.Base58Encode(containerID))
+
+	storage.Put(ctx, aliasKey, domain)
+
+	runtime.Log("container alias has been set")
+	runtime.Notify("SetAliasSuccess", containerID, domain)
+}
+
+// DeleteAlias method removes the nice-name alias of the container from NNS
+// contract if it has been invoked by Alphabet nodes of the Inner Ring.
+// Otherwise, it produces deleteAlias notification.
+//
+// Signature is a RFC6979 signature of the container ID.
+// Token is optional and should be a stable marshaled SessionToken structure from
+// API.
+//
+// If the container doesn't exist, it panics with NotFoundError.
+func DeleteAlias(containerID []byte, signature interop.Signature, token []byte) {
+	ctx := storage.GetContext()
+	notaryDisabled := storage.Get(ctx, notaryDisabledKey).(bool)
+
+	ownerID := getOwnerByID(ctx, containerID)
+	if ownerID == nil {
+		panic(NotFoundError)
+	}
+
+	if isRemoved(ctx, containerID) {
+		panic(RemovedError)
+	}
+
+	if storage.Get(ctx, append([]byte(nnsHasAliasKey), containerID...)) == nil {
+		return
+	}
+
+	if notaryDisabled {
+		alphabet := common.AlphabetNodes()
+		nodeKey := common.InnerRingInvoker(alphabet)
+		if len(nodeKey) == 0 {
+			runtime.Notify("deleteAlias", containerID, signature, token)
+			return
+		}
+
+		threshold := len(alphabet)*2/3 + 1
+		id := common.InvokeID([]interface{}{containerID, signature}, []byte("deleteAlias"))
+
+		n := common.Vote(ctx, id, nodeKey)
+		if n < threshold {
+			return
+		}
+
+		common.RemoveVotes(ctx, id)
+	} else {
+		multiaddr := common.AlphabetAddress()
+		common.CheckAlphabetWitness(multiaddr)
+	}
+
+	domain := deleteAlias(ctx, containerID)
+
+	runtime.Log("container alias has been deleted")
+	runtime.Notify("DeleteAliasSuccess", containerID, domain)
+}
+
 // Get method returns a structure that contains a stable marshaled Container structure,
 // the signature, the public key of the container creator and a stable marshaled SessionToken
 // structure if it was provided.
@@ -389,17 +781,37 @@ func Owner(containerID []byte) []byte {
 
 // Count method returns the number of registered containers.
 func Count() int {
-	count := 0
 	ctx := storage.GetReadOnlyContext()
-	it := storage.Find(ctx, []byte{}, storage.KeysOnly)
-	for iterator.Next(it) {
-		key := iterator.Value(it).([]byte)
-		// V2 format
-		if len(key) == containerIDSize {
-			count++
-		}
+	return storage.Get(ctx, containerCountKey).(int)
+}
+
+// CountOf method returns the number of containers owned by the specified owner.
+func CountOf(owner []byte) int {
+	if len(owner) != ownerIDSize {
+		panic("invalid owner")
+	}
+
+	ctx := storage.GetReadOnlyContext()
+	count := storage.Get(ctx, append([]byte(ownerCountPrefix), owner...))
+	if count == nil {
+		return 0
+	}
+	return count.(int)
+}
+
+// ContainersOf method returns an iterator over IDs of the containers owned by
+// the specified owner. If owner is empty, it iterates over all containers.
+// Use it instead of List method for big container lists.
+func ContainersOf(owner []byte) iterator.Iterator {
+	ctx := storage.GetReadOnlyContext()
+	if len(owner) == 0 {
+		return storage.Find(ctx, []byte(containerIndexPrefix), storage.ValuesOnly)
+	}
+
+	if len(owner) != ownerIDSize {
+		panic("invalid owner")
 	}
-	return count
+	return storage.Find(ctx, owner, storage.ValuesOnly)
 }
 
 // List method returns a list of all container IDs owned by the specified owner.
@@ -447,6 +859,10 @@ func SetEACL(eACL []byte, signature interop.Signature, publicKey interop.PublicK
 		panic(NotFoundError)
 	}
 
+	if isRemoved(ctx, containerID) {
+		panic(RemovedError)
+	}
+
 	if notaryDisabled {
 		alphabet := common.AlphabetNodes()
 		nodeKey := common.InnerRingInvoker(alphabet)
@@ -480,17 +896,85 @@ func SetEACL(eACL []byte, signature interop.Signature, publicKey interop.PublicK
 
 	common.SetSerialized(ctx, key, rule)
 
+	netmapContractAddr := storage.Get(ctx, netmapContractKey).(interop.Hash160)
+	epoch := contract.Call(netmapContractAddr, "epoch", contract.ReadOnly).(int)
+	putEACLHistory(ctx, containerID, epoch, rule)
+
 	runtime.Log("success")
 	runtime.Notify("SetEACLSuccess", containerID, publicKey)
 }
 
-// EACL method returns a structure that contains a stable marshaled EACLTable structure,
-// the signature, the public key of the extended ACL setter and a stable marshaled SessionToken
-// structure if it was provided.
+// RemoveEACL method removes the extended ACL table of the container if it has
+// been invoked by Alphabet nodes of the Inner Ring. Otherwise, it produces
+// removeEACL notification.
+//
+// Signature is a RFC6979 signature of the container ID.
+// Token is optional and should be a stable marshaled SessionToken structure from
+// API.
 //
 // If the container doesn't exist, it panics with NotFoundError.
-func EACL(containerID []byte) ExtendedACL {
-	ctx := storage.GetReadOnlyContext()
+func RemoveEACL(containerID []byte, signature interop.Signature, token []byte) {
+	ctx := storage.GetContext()
+	notaryDisabled := storage.Get(ctx, notaryDisabledKey).(bool)
+
+	ownerID := getOwnerByID(ctx, containerID)
+	if ownerID == nil {
+		panic(NotFoundError)
+	}
+
+	if isRemoved(ctx, containerID) {
+		panic(RemovedError)
+	}
+
+	key := append(eACLPrefix, containerID...)
+	if storage.Get(ctx, key) == nil {
+		return
+	}
+
+	if notaryDisabled {
+		alphabet := common.AlphabetNodes()
+		nodeKey := common.InnerRingInvoker(alphabet)
+		if len(nodeKey) == 0 {
+			runtime.Notify("removeEACL", containerID, signature, token)
+			return
+		}
+
+		threshold := len(alphabet)*2/3 + 1
+		id := common.InvokeID([]interface{}{containerID, signature}, []byte("removeEACL"))
+
+		n := common.Vote(ctx, id, nodeKey)
+		if n < threshold {
+			return
+		}
+
+		common.RemoveVotes(ctx, id)
+	} else {
+		multiaddr := common.AlphabetAddress()
+		common.CheckAlphabetWitness(multiaddr)
+	}
+
+	storage.Delete(ctx, key)
+
+	netmapContractAddr := storage.Get(ctx, netmapContractKey).(interop.Hash160)
+	epoch := contract.Call(netmapContractAddr, "epoch", contract.ReadOnly).(int)
+	putEACLHistory(ctx, containerID, epoch, ExtendedACL{
+		value: []byte{},
+		sig:   interop.Signature{},
+		pub:   interop.PublicKey{},
+		token: []byte{},
+	})
+
+	runtime.Log("extended ACL removed")
+	runtime.Notify("RemoveEACLSuccess", containerID)
+}
+
+// EACL method returns a structure that contains a stable marshaled EACLTable structure,
+// the signature, the public key of the extended ACL setter and a stable marshaled SessionToken
+// structure if it was provided.
+//
+// If the container doesn't exist, it panics with NotFoundError.
+func EACL(containerID []byte) ExtendedACL {
+	ctx := storage.GetReadOnlyContext()
 
 	ownerID := getOwnerByID(ctx, containerID)
 	if ownerID == nil {
@@ -500,11 +984,69 @@ func EACL(containerID []byte) ExtendedACL {
 	return getEACL(ctx, containerID)
 }
 
+// EACLAt method returns the extended ACL of the container in effect at the
+// end of the specified epoch in the same format as EACL method. The value is
+// empty if the extended ACL was not set or was removed in that epoch.
+//
+// If the container doesn't exist, it panics with NotFoundError.
+func EACLAt(containerID []byte, epoch int) ExtendedACL {
+	ctx := storage.GetReadOnlyContext()
+
+	ownerID := getOwnerByID(ctx, containerID)
+	if ownerID == nil {
+		panic(NotFoundError)
+	}
+
+	rule := ExtendedACL{value: []byte{}, sig: interop.Signature{}, pub: interop.PublicKey{}, token: []byte{}}
+
+	prefix := append([]byte(eACLHistoryPrefix), containerID...)
+	it := storage.Find(ctx, prefix, storage.ValuesOnly|storage.DeserializeValues)
+	for iterator.Next(it) {
+		version := iterator.Value(it).(eACLVersion)
+		if version.epoch > epoch {
+			break
+		}
+
+		rule = version.eACL
+	}
+
+	return rule
+}
+
+// EACLVersions method returns an array of structures that contain the epoch
+// and the extended ACL of the container set in that epoch in the same format
+// as EACL method, ordered by epoch. Empty extended ACL value means that
+// the extended ACL has been removed. Only the last change is kept for
+// every epoch.
+//
+// If the container doesn't exist, it panics with NotFoundError.
+func EACLVersions(containerID []byte) []eACLVersion {
+	ctx := storage.GetReadOnlyContext()
+
+	ownerID := getOwnerByID(ctx, containerID)
+	if ownerID == nil {
+		panic(NotFoundError)
+	}
+
+	versions := []eACLVersion{}
+
+	prefix := append([]byte(eACLHistoryPrefix), containerID...)
+	it := storage.Find(ctx, prefix, storage.ValuesOnly|storage.DeserializeValues)
+	for iterator.Next(it) {
+		versions = append(versions, iterator.Value(it).(eACLVersion))
+	}
+
+	return versions
+}
+
 // PutContainerSize method saves container size estimation in contract
 // memory. It can be invoked only by Storage nodes from the network map. This method
 // checks witness based on the provided public key of the Storage node.
+// It produces QuotaExceeded notification if the estimation exceeds
+// the container quota, see SetQuota method.
 //
-// If the container doesn't exist, it panics with NotFoundError.
+// If the container doesn't exist, it panics with NotFoundError. If it has been
+// removed, it panics with RemovedError.
 func PutContainerSize(epoch int, cid []byte, usedSize int, pubKey interop.PublicKey) {
 	ctx := storage.GetContext()
 
@@ -512,23 +1054,52 @@ func PutContainerSize(epoch int, cid []byte, usedSize int, pubKey interop.Public
 		panic(NotFoundError)
 	}
 
+	if isRemoved(ctx, cid) {
+		panic(RemovedError)
+	}
+
 	common.CheckWitness(pubKey)
 
 	if !isStorageNode(ctx, pubKey) {
 		panic("method must be invoked by storage node from network map")
 	}
 
-	key := estimationKey(epoch, cid, pubKey)
+	putContainerSize(ctx, epoch, cid, usedSize, pubKey)
 
-	s := estimation{
-		from: pubKey,
-		size: usedSize,
+	runtime.Log("saved container size estimation")
+}
+
+// PutContainerSizes method saves container size estimations of the Storage
+// node in contract memory the same way as PutContainerSize method does for
+// every estimation, but checks witness and network map only once.
+//
+// Estimations is an array of container ID and container size pairs.
+//
+// If any container doesn't exist, it panics with NotFoundError. If any
+// container has been removed, it panics with RemovedError.
+func PutContainerSizes(epoch int, estimations []sizeEstimation, pubKey interop.PublicKey) {
+	ctx := storage.GetContext()
+
+	common.CheckWitness(pubKey)
+
+	if !isStorageNode(ctx, pubKey) {
+		panic("method must be invoked by storage node from network map")
 	}
 
-	storage.Put(ctx, key, std.Serialize(s))
-	updateEstimations(ctx, epoch, cid, pubKey, false)
+	for i := range estimations {
+		cid := estimations[i].cid
+		if getOwnerByID(ctx, cid) == nil {
+			panic(NotFoundError)
+		}
 
-	runtime.Log("saved container size estimation")
+		if isRemoved(ctx, cid) {
+			panic(RemovedError)
+		}
+
+		putContainerSize(ctx, epoch, cid, estimations[i].size, pubKey)
+	}
+
+	runtime.Log("saved container size estimations")
 }
 
 // GetContainerSize method returns the container ID and a slice of container
@@ -582,6 +1153,64 @@ func ListContainerSizes(epoch int) [][]byte {
 	return result
 }
 
+// ContainerUsage method returns a structure that contains the median and
+// the mean of the container size estimations of the specified epoch and
+// the number of Storage nodes that reported them. It returns Null if
+// the estimations were not aggregated, see StopContainerEstimation method.
+// Aggregated usage is removed from contract storage after UsageCleanupDelta
+// epochs.
+func ContainerUsage(cid []byte, epoch int) interface{} {
+	ctx := storage.GetReadOnlyContext()
+
+	data := storage.Get(ctx, usageKey(epoch, cid))
+	if data == nil {
+		return nil
+	}
+
+	return std.Deserialize(data.([]byte)).(containerUsage)
+}
+
+// OwnerUsage method returns the sum of the container sizes of the owner in
+// the specified epoch. Container size is a median of its size estimations,
+// see ContainerUsage method. Owner usage is removed from contract storage
+// after the number of epochs specified in ContainerOwnerUsageRetention
+// netmap config.
+func OwnerUsage(owner []byte, epoch int) int {
+	if len(owner) != ownerIDSize {
+		panic("invalid owner")
+	}
+
+	ctx := storage.GetReadOnlyContext()
+
+	data := storage.Get(ctx, ownerUsageKey(owner, epoch))
+	if data == nil {
+		return 0
+	}
+
+	usage := std.Deserialize(data.([]byte)).(ownerUsage)
+	return usage.size
+}
+
+// OwnerUsageHistory method returns an array of structures that contain
+// the epoch and the sum of the container sizes of the owner in that epoch
+// ordered by epoch.
+func OwnerUsageHistory(owner []byte) []ownerUsage {
+	if len(owner) != ownerIDSize {
+		panic("invalid owner")
+	}
+
+	ctx := storage.GetReadOnlyContext()
+
+	history := []ownerUsage{}
+
+	it := storage.Find(ctx, append([]byte(ownerUsagePrefix), owner...), storage.ValuesOnly|storage.DeserializeValues)
+	for iterator.Next(it) {
+		history = append(history, iterator.Value(it).(ownerUsage))
+	}
+
+	return history
+}
+
 // NewEpoch method removes all container size estimations from epoch older than
 // epochNum + 3. It can be invoked only by NewEpoch method of the Netmap contract.
 func NewEpoch(epochNum int) {
@@ -603,6 +1232,10 @@ func NewEpoch(epochNum int) {
 	}
 
 	cleanupContainers(ctx, epochNum)
+	cleanupSizeIndex(ctx, epochNum)
+	cleanupUsage(ctx, epochNum)
+	cleanupOwnerUsage(ctx, epochNum)
+	purgeContainers(ctx, epochNum)
 }
 
 // StartContainerEstimation method produces StartEstimation notification.
@@ -645,6 +1278,10 @@ func StartContainerEstimation(epoch int) {
 
 // StopContainerEstimation method produces StopEstimation notification.
 // It can be invoked only by Alphabet nodes of the Inner Ring.
+//
+// Storage nodes put container size estimations of the epoch after the
+// notification, so the estimations of the previous epoch are complete by then.
+// They are aggregated and available via ContainerUsage method.
 func StopContainerEstimation(epoch int) {
 	ctx := storage.GetContext()
 	notaryDisabled := storage.Get(ctx, notaryDisabledKey).(bool)
@@ -677,6 +1314,10 @@ func StopContainerEstimation(epoch int) {
 		common.RemoveVotes(ctx, id)
 	}
 
+	if epoch > 0 {
+		aggregateUsage(ctx, epoch-1)
+	}
+
 	runtime.Notify("StopEstimation", epoch)
 	runtime.Log("notification has been produced")
 }
@@ -686,35 +1327,181 @@ func Version() int {
 	return common.Version
 }
 
-func addContainer(ctx storage.Context, id, owner []byte, container Container) {
-	containerListKey := append(owner, id...)
-	storage.Put(ctx, containerListKey, id)
+func addContainer(ctx storage.Context, id, owner []byte, container Container, attrs []containerAttribute) {
+	if storage.Get(ctx, id) == nil {
+		storage.Put(ctx, append([]byte(containerIndexPrefix), id...), id)
+		updateContainerCount(ctx, owner, 1)
+
+		containerListKey := append(owner, id...)
+		storage.Put(ctx, containerListKey, id)
+
+		indexAttributes(ctx, id, attrs)
+	}
 
 	common.SetSerialized(ctx, id, container)
 }
 
-func removeContainer(ctx storage.Context, id []byte, owner []byte) {
+// deleteAlias removes the nice-name alias of the container and returns its
+// domain or an empty string if the container has no alias.
+func deleteAlias(ctx storage.Context, containerID []byte) string {
+	key := append([]byte(nnsHasAliasKey), containerID...)
+	domain := storage.Get(ctx, key).(string)
+	if len(domain) != 0 {
+		storage.Delete(ctx, key)
+		// We should do `getRecord` first because NNS record could be deleted
+		// by other means (expiration, manual), thus leading to failing `deleteRecord`
+		// and inability to delete a container. We should also check if we own the record in case.
+		nnsContractAddr := storage.Get(ctx, nnsContractKey).(interop.Hash160)
+		res := contract.Call(nnsContractAddr, "getRecords", contract.ReadStates|contract.AllowCall, domain, 16 /* TXT */)
+		if res != nil && std.Base58Encode(containerID) == string(res.([]interface{})[0].(string)) {
+			contract.Call(nnsContractAddr, "deleteRecords", contract.All, domain, 16 /* TXT */)
+		}
+	}
+
+	return domain
+}
+
+// removeContainer removes the container from the container index and marks
+// it as removed. Container metadata is kept until the purge epoch ends.
+func removeContainer(ctx storage.Context, id []byte, owner []byte, epoch, purgeEpoch int) {
 	containerListKey := append(owner, id...)
 	storage.Delete(ctx, containerListKey)
 
+	storage.Delete(ctx, append([]byte(containerIndexPrefix), id...))
+	updateContainerCount(ctx, owner, -1)
+
+	cnt := getContainer(ctx, id)
+	unindexAttributes(ctx, id, parseContainerAttributes(cnt.value))
+
+	storage.Put(ctx, append([]byte(removedPrefix), id...), epoch)
+
+	purgeKey := append([]byte(purgePrefix), common.EpochKey(purgeEpoch)...)
+	storage.Put(ctx, append(purgeKey, id...), id)
+}
+
+// purgeContainer deletes the metadata of the removed container.
+func purgeContainer(ctx storage.Context, id []byte) {
+	storage.Delete(ctx, append(eACLPrefix, id...))
+	removeQuota(ctx, id)
+
+	it := storage.Find(ctx, append([]byte(eACLHistoryPrefix), id...), storage.KeysOnly)
+	for iterator.Next(it) {
+		storage.Delete(ctx, iterator.Value(it).([]byte))
+	}
+
+	storage.Delete(ctx, append([]byte(ownerOverridePrefix), id...))
+	storage.Delete(ctx, append([]byte(ownerHistoryPrefix), id...))
+
 	storage.Delete(ctx, id)
 }
 
-func getAllContainers(ctx storage.Context) [][]byte {
-	var list [][]byte
+// purgeContainers deletes the metadata of the removed containers which grace
+// period has ended before the specified epoch.
+func purgeContainers(ctx storage.Context, epoch int) {
+	it := storage.Find(ctx, []byte(purgePrefix), storage.RemovePrefix)
+	for iterator.Next(it) {
+		kv := iterator.Value(it).(struct {
+			key []byte
+			val []byte
+		})
+		if common.EpochFromKey(kv.key) >= epoch {
+			break
+		}
+
+		storage.Delete(ctx, append([]byte(purgePrefix), kv.key...))
+		purgeContainer(ctx, kv.val)
+	}
+}
+
+func isRemoved(ctx storage.Context, id []byte) bool {
+	return storage.Get(ctx, append([]byte(removedPrefix), id...)) != nil
+}
+
+// updateContainerCount adds delta to the global and the owner container counters.
+func updateContainerCount(ctx storage.Context, owner []byte, delta int) {
+	count := storage.Get(ctx, containerCountKey).(int)
+	storage.Put(ctx, containerCountKey, count+delta)
+
+	updateOwnerCount(ctx, owner, delta)
+}
+
+// updateOwnerCount adds delta to the owner container counter.
+func updateOwnerCount(ctx storage.Context, owner []byte, delta int) {
+	ownerKey := append([]byte(ownerCountPrefix), owner...)
+	ownerCount := delta
+	data := storage.Get(ctx, ownerKey)
+	if data != nil {
+		ownerCount += data.(int)
+	}
+
+	if ownerCount > 0 {
+		storage.Put(ctx, ownerKey, ownerCount)
+	} else {
+		storage.Delete(ctx, ownerKey)
+	}
+}
+
+// migrateContainerIndex builds the container index and counters from
+// the containers stored before they were supported.
+func migrateContainerIndex(ctx storage.Context) {
+	if storage.Get(ctx, containerCountKey) != nil {
+		return
+	}
+
+	storage.Put(ctx, containerCountKey, 0)
 
 	it := storage.Find(ctx, []byte{}, storage.KeysOnly)
 	for iterator.Next(it) {
 		key := iterator.Value(it).([]byte) // it MUST BE `storage.KeysOnly`
 		// V2 format
 		if len(key) == containerIDSize {
-			list = append(list, key)
+			storage.Put(ctx, append([]byte(containerIndexPrefix), key...), key)
+			updateContainerCount(ctx, getOwnerByID(ctx, key), 1)
 		}
 	}
+}
+
+func getAllContainers(ctx storage.Context) [][]byte {
+	var list [][]byte
+
+	it := storage.Find(ctx, []byte(containerIndexPrefix), storage.ValuesOnly)
+	for iterator.Next(it) {
+		list = append(list, iterator.Value(it).([]byte))
+	}
 
 	return list
 }
 
+// putEACLHistory records the extended ACL of the container set in the
+// specified epoch.
+func putEACLHistory(ctx storage.Context, cid []byte, epoch int, rule ExtendedACL) {
+	key := append([]byte(eACLHistoryPrefix), cid...)
+	common.SetSerialized(ctx, append(key, common.EpochKey(epoch)...), eACLVersion{
+		epoch: epoch,
+		eACL:  rule,
+	})
+}
+
+// migrateEACLHistory records the current extended ACLs of the containers
+// which have no history yet.
+func migrateEACLHistory(ctx storage.Context) {
+	netmapContractAddr := storage.Get(ctx, netmapContractKey).(interop.Hash160)
+	epoch := contract.Call(netmapContractAddr, "epoch", contract.ReadOnly).(int)
+
+	it := storage.Find(ctx, eACLPrefix, storage.RemovePrefix)
+	for iterator.Next(it) {
+		kv := iterator.Value(it).(struct {
+			key []byte
+			val []byte
+		})
+
+		hist := storage.Find(ctx, append([]byte(eACLHistoryPrefix), kv.key...), storage.KeysOnly)
+		if !iterator.Next(hist) {
+			putEACLHistory(ctx, kv.key, epoch, std.Deserialize(kv.val).(ExtendedACL))
+		}
+	}
+}
+
 func getEACL(ctx storage.Context, cid []byte) ExtendedACL {
 	key := append(eACLPrefix, cid...)
 	data := storage.Get(ctx, key)
@@ -740,9 +1527,23 @@ func getOwnerByID(ctx storage.Context, cid []byte) []byte {
 		return nil
 	}
 
+	owner := storage.Get(ctx, append([]byte(ownerOverridePrefix), cid...))
+	if owner != nil {
+		return owner.([]byte)
+	}
+
 	return ownerFromBinaryContainer(container.value)
 }
 
+func getOwnershipHistory(ctx storage.Context, cid []byte) []ownershipTransfer {
+	data := storage.Get(ctx, append([]byte(ownerHistoryPrefix), cid...))
+	if data != nil {
+		return std.Deserialize(data.([]byte)).([]ownershipTransfer)
+	}
+
+	return []ownershipTransfer{}
+}
+
 func ownerFromBinaryContainer(container []byte) []byte {
 	// V2 format
 	offset := int(container[1])
@@ -750,6 +1551,33 @@ func ownerFromBinaryContainer(container []byte) []byte {
 	return container[offset : offset+25] // offset + size of owner
 }
 
+// putContainerSize saves container size estimation of the Storage node.
+func putContainerSize(ctx storage.Context, epoch int, cid []byte, usedSize int, pubKey interop.PublicKey) {
+	if usedSize < 0 {
+		panic("invalid container size")
+	}
+
+	key := estimationKey(epoch, cid, pubKey)
+	hash := crypto.Ripemd160(pubKey)
+
+	// the node can report the estimation again, so the old size is unindexed
+	data := storage.Get(ctx, key)
+	if data != nil {
+		old := std.Deserialize(data.([]byte)).(estimation)
+		storage.Delete(ctx, sizeIndexKey(epoch, cid, old.size, hash))
+	}
+
+	s := estimation{
+		from: pubKey,
+		size: usedSize,
+	}
+
+	storage.Put(ctx, key, std.Serialize(s))
+	storage.Put(ctx, sizeIndexKey(epoch, cid, usedSize, hash), usedSize)
+	updateEstimations(ctx, epoch, cid, pubKey, false)
+	checkQuota(ctx, epoch, cid, usedSize)
+}
+
 func estimationKey(epoch int, cid []byte, key interop.PublicKey) []byte {
 	var buf interface{} = epoch
 
@@ -783,13 +1611,24 @@ func isStorageNode(ctx storage.Context, key interop.PublicKey) bool {
 	netmapContractAddr := storage.Get(ctx, netmapContractKey).(interop.Hash160)
 	snapshot := contract.Call(netmapContractAddr, "snapshot", contract.ReadOnly, 1).([]storageNode)
 
+	// node key could have been rotated since the previous epoch,
+	// so the keys it replaced are looked up too
+	keys := []interop.PublicKey{key}
+	prev := contract.Call(netmapContractAddr, "previousNodeKey", contract.ReadOnly, key)
+	for prev != nil {
+		keys = append(keys, prev.(interop.PublicKey))
+		prev = contract.Call(netmapContractAddr, "previousNodeKey", contract.ReadOnly, prev)
+	}
+
 	for i := range snapshot {
 		// V2 format
 		nodeInfo := snapshot[i].info
 		nodeKey := nodeInfo[2:35] // offset:2, len:33
 
-		if common.BytesEqual(key, nodeKey) {
-			return true
+		for j := range keys {
+			if common.BytesEqual(keys[j], nodeKey) {
+				return true
+			}
 		}
 	}
 
@@ -811,6 +1650,12 @@ func updateEstimations(ctx storage.Context, epoch int, cid []byte, pub interop.P
 				key := append([]byte(estimateKeyPrefix), convert.ToBytes(oldEpoch)...)
 				key = append(key, cid...)
 				key = append(key, h[:estimatePostfixSize]...)
+
+				data := storage.Get(ctx, key)
+				if data != nil {
+					old := std.Deserialize(data.([]byte)).(estimation)
+					storage.Delete(ctx, sizeIndexKey(oldEpoch, cid, old.size, h))
+				}
 				storage.Delete(ctx, key)
 			} else {
 				newEpochs = append(newEpochs, oldEpoch)
@@ -822,6 +1667,188 @@ func updateEstimations(ctx storage.Context, epoch int, cid []byte, pub interop.P
 	common.SetSerialized(ctx, estKey, newEpochs)
 }
 
+// aggregateUsage aggregates container size estimations of the specified epoch.
+// Estimations are read from the size index, so the sizes of each container
+// are already sorted.
+func aggregateUsage(ctx storage.Context, epoch int) {
+	prefix := append([]byte(sizeIndexPrefix), common.EpochKey(epoch)...)
+
+	var (
+		cid   []byte
+		sizes []int
+	)
+
+	it := storage.Find(ctx, prefix, storage.KeysOnly|storage.RemovePrefix)
+	for iterator.Next(it) {
+		key := iterator.Value(it).([]byte)
+
+		id := key[:containerIDSize]
+		if cid != nil && !common.BytesEqual(cid, id) {
+			putUsage(ctx, epoch, cid, sizes)
+			sizes = []int{}
+		}
+
+		cid = id
+		sizes = append(sizes, common.EpochFromKey(key[containerIDSize:]))
+	}
+
+	if cid != nil {
+		putUsage(ctx, epoch, cid, sizes)
+	}
+}
+
+// putUsage stores the median and the mean of the container sizes sorted
+// in ascending order.
+func putUsage(ctx storage.Context, epoch int, cid []byte, sizes []int) {
+	sum := 0
+	for i := range sizes {
+		sum += sizes[i]
+	}
+
+	n := len(sizes)
+	median := sizes[n/2]
+	if n%2 == 0 {
+		median = (sizes[n/2-1] + sizes[n/2]) / 2
+	}
+
+	key := usageKey(epoch, cid)
+
+	// estimations can be aggregated again, so the owner usage is
+	// updated with the difference
+	delta := median
+	data := storage.Get(ctx, key)
+	if data != nil {
+		old := std.Deserialize(data.([]byte)).(containerUsage)
+		delta -= old.median
+	}
+
+	common.SetSerialized(ctx, key, containerUsage{
+		median:    median,
+		mean:      sum / n,
+		reporters: n,
+	})
+
+	owner := getOwnerByID(ctx, cid)
+	if owner != nil {
+		updateOwnerUsage(ctx, owner, epoch, delta)
+	}
+}
+
+// updateOwnerUsage adds delta to the owner usage of the specified epoch.
+func updateOwnerUsage(ctx storage.Context, owner []byte, epoch, delta int) {
+	key := ownerUsageKey(owner, epoch)
+
+	usage := ownerUsage{
+		epoch: epoch,
+		size:  delta,
+	}
+
+	data := storage.Get(ctx, key)
+	if data != nil {
+		old := std.Deserialize(data.([]byte)).(ownerUsage)
+		usage.size += old.size
+	}
+
+	common.SetSerialized(ctx, key, usage)
+
+	indexKey := append([]byte(ownerUsageEpochPrefix), common.EpochKey(epoch)...)
+	storage.Put(ctx, append(indexKey, owner...), owner)
+}
+
+func ownerUsageKey(owner []byte, epoch int) []byte {
+	key := append([]byte(ownerUsagePrefix), owner...)
+	return append(key, common.EpochKey(epoch)...)
+}
+
+// cleanupOwnerUsage removes owner usage older than the number of epochs
+// specified in ContainerOwnerUsageRetention netmap config.
+func cleanupOwnerUsage(ctx storage.Context, epoch int) {
+	netmapContractAddr := storage.Get(ctx, netmapContractKey).(interop.Hash160)
+	retention := UsageCleanupDelta
+	val := contract.Call(netmapContractAddr, "config", contract.ReadOnly, OwnerUsageRetentionKey)
+	if val != nil {
+		retention = val.(int)
+	}
+
+	it := storage.Find(ctx, []byte(ownerUsageEpochPrefix), storage.RemovePrefix)
+	for iterator.Next(it) {
+		kv := iterator.Value(it).(struct {
+			key []byte
+			val []byte
+		})
+
+		usageEpoch := common.EpochFromKey(kv.key)
+		if epoch-usageEpoch <= retention {
+			break
+		}
+
+		storage.Delete(ctx, append([]byte(ownerUsageEpochPrefix), kv.key...))
+		storage.Delete(ctx, ownerUsageKey(kv.val, usageEpoch))
+	}
+}
+
+func usageKey(epoch int, cid []byte) []byte {
+	key := append([]byte(usagePrefix), common.EpochKey(epoch)...)
+	return append(key, cid...)
+}
+
+// cleanupUsage removes aggregated container usage older than UsageCleanupDelta
+// epochs.
+func cleanupUsage(ctx storage.Context, epoch int) {
+	it := storage.Find(ctx, []byte(usagePrefix), storage.KeysOnly|storage.RemovePrefix)
+	for iterator.Next(it) {
+		k := iterator.Value(it).([]byte)
+		if epoch-common.EpochFromKey(k) <= UsageCleanupDelta {
+			break
+		}
+
+		storage.Delete(ctx, append([]byte(usagePrefix), k...))
+	}
+}
+
+// sizeIndexKey returns the key of the size index entry of the container size
+// estimation, hash is the RIPEMD160 hash of the Storage node key.
+func sizeIndexKey(epoch int, cid []byte, size int, hash []byte) []byte {
+	key := append([]byte(sizeIndexPrefix), common.EpochKey(epoch)...)
+	key = append(key, cid...)
+	key = append(key, common.EpochKey(size)...)
+	return append(key, hash[:estimatePostfixSize]...)
+}
+
+// cleanupSizeIndex removes size index entries of the estimations removed by
+// cleanupContainers.
+func cleanupSizeIndex(ctx storage.Context, epoch int) {
+	it := storage.Find(ctx, []byte(sizeIndexPrefix), storage.KeysOnly|storage.RemovePrefix)
+	for iterator.Next(it) {
+		k := iterator.Value(it).([]byte)
+		if epoch-common.EpochFromKey(k) <= TotalCleanupDelta {
+			break
+		}
+
+		storage.Delete(ctx, append([]byte(sizeIndexPrefix), k...))
+	}
+}
+
+// migrateSizeIndex builds the size index of the container size estimations
+// stored before it was supported.
+func migrateSizeIndex(ctx storage.Context) {
+	it := storage.Find(ctx, []byte(estimateKeyPrefix), storage.DeserializeValues)
+	for iterator.Next(it) {
+		kv := iterator.Value(it).(struct {
+			key []byte
+			val estimation
+		})
+		// V2 format
+		postfix := kv.key[len(kv.key)-containerIDSize-estimatePostfixSize:]
+		nbytes := kv.key[len(estimateKeyPrefix) : len(kv.key)-containerIDSize-estimatePostfixSize]
+
+		var n interface{} = nbytes
+
+		key := sizeIndexKey(n.(int), postfix[:containerIDSize], kv.val.size, postfix[containerIDSize:])
+		storage.Put(ctx, key, kv.val.size)
+	}
+}
+
 func cleanupContainers(ctx storage.Context, epoch int) {
 	it := storage.Find(ctx, []byte(estimateKeyPrefix), storage.KeysOnly)
 	for iterator.Next(it) {
@@ -4,8 +4,8 @@ Container contract is a contract deployed in NeoFS sidechain.
 Container contract stores and manages containers, extended ACLs and container
 size estimations. Contract does not perform sanity or signature checks of
 containers or extended ACLs, it is done by Alphabet nodes of the Inner Ring.
-Alphabet nodes approve it by invoking the same Put or SetEACL methods with
-the same arguments.
+Alphabet nodes approve it by invoking the same Put, SetEACL or TransferOwnership
+methods with the same arguments.
 
 Contract notifications
 
@@ -35,6 +35,55 @@ and validate container ownership, signature and token if present.
     - name: token
       type: ByteArray
 
+containerTransferOwnership notification. This notification is produced when
+a container owner wants to transfer the container to another owner. Alphabet
+nodes of the Inner Ring catch the notification and validate container
+ownership, signature and token if present.
+
+  containerTransferOwnership:
+    - name: containerID
+      type: ByteArray
+    - name: newOwner
+      type: ByteArray
+    - name: signature
+      type: Signature
+    - name: publicKey
+      type: PublicKey
+    - name: token
+      type: ByteArray
+
+setAlias notification. This notification is produced when a container owner
+wants to set a nice-name alias of the container. Alphabet nodes of the Inner Ring
+catch the notification and validate container ownership, signature and token if
+present.
+
+  setAlias:
+    - name: containerID
+      type: ByteArray
+    - name: name
+      type: String
+    - name: zone
+      type: String
+    - name: signature
+      type: Signature
+    - name: publicKey
+      type: PublicKey
+    - name: token
+      type: ByteArray
+
+deleteAlias notification. This notification is produced when a container owner
+wants to remove a nice-name alias of the container. Alphabet nodes of the Inner
+Ring catch the notification and validate container ownership, signature and
+token if present.
+
+  deleteAlias:
+    - name: containerID
+      type: ByteArray
+    - name: signature
+      type: Signature
+    - name: token
+      type: ByteArray
+
 setEACL notification. This notification is produced when a container owner wants
 to update an extended ACL of a container. Alphabet nodes of the Inner Ring catch
 the notification and validate container ownership, signature and token if
@@ -50,6 +99,67 @@ present.
     - name: token
       type: ByteArray
 
+removeEACL notification. This notification is produced when a container owner
+wants to remove an extended ACL of a container. Alphabet nodes of the Inner Ring
+catch the notification and validate container ownership, signature and token if
+present.
+
+  removeEACL:
+    - name: containerID
+      type: ByteArray
+    - name: signature
+      type: Signature
+    - name: token
+      type: ByteArray
+
+setQuota notification. This notification is produced when a container owner
+wants to set soft and hard limits of the container size. Alphabet nodes of the
+Inner Ring catch the notification and validate container ownership, signature
+and token if present.
+
+  setQuota:
+    - name: containerID
+      type: ByteArray
+    - name: softLimit
+      type: Integer
+    - name: hardLimit
+      type: Integer
+    - name: signature
+      type: Signature
+    - name: publicKey
+      type: PublicKey
+    - name: token
+      type: ByteArray
+
+clearQuota notification. This notification is produced when a container owner
+wants to remove limits of the container size. Alphabet nodes of the Inner Ring
+catch the notification and validate container ownership, signature and token if
+present.
+
+  clearQuota:
+    - name: containerID
+      type: ByteArray
+    - name: signature
+      type: Signature
+    - name: publicKey
+      type: PublicKey
+    - name: token
+      type: ByteArray
+
+QuotaExceeded notification. This notification is produced when a container size
+estimation exceeds the soft or the hard limit of the container size. Storage
+nodes and the Inner Ring may restrict further container usage.
+
+  QuotaExceeded:
+    - name: containerID
+      type: ByteArray
+    - name: epoch
+      type: Integer
+    - name: size
+      type: Integer
+    - name: hard
+      type: Boolean
+
 StartEstimation notification. This notification is produced when Storage nodes
 should exchange estimation values of container sizes among other Storage nodes.
 
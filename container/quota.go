@@ -0,0 +1,272 @@
+package container
+
+import (
+	"github.com/nspcc-dev/neo-go/pkg/interop"
+	"github.com/nspcc-dev/neo-go/pkg/interop/iterator"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/std"
+	"github.com/nspcc-dev/neo-go/pkg/interop/runtime"
+	"github.com/nspcc-dev/neo-go/pkg/interop/storage"
+	"github.com/nspcc-dev/neofs-contract/common"
+)
+
+type (
+	// containerQuota contains soft and hard limits of the container size.
+	// Zero limit means that the size is not limited.
+	containerQuota struct {
+		soft  int
+		hard  int
+		sig   interop.Signature
+		pub   interop.PublicKey
+		token []byte
+	}
+
+	// quotaBreach is the biggest container size estimation of an epoch
+	// which exceeds the container quota.
+	quotaBreach struct {
+		epoch int
+		size  int
+		hard  bool
+	}
+)
+
+const (
+	quotaPrefix = "quota_"
+	// Quota breach keys are prefix + container ID + epoch.
+	quotaBreachPrefix = "quotaBreach_"
+
+	// ErrInvalidQuota is thrown when quota limits are negative or soft limit
+	// is bigger than hard one.
+	ErrInvalidQuota = "invalid quota"
+)
+
+// SetQuota method sets soft and hard limits of the container size if it has
+// been invoked by Alphabet nodes of the Inner Ring. Otherwise, it produces
+// setQuota notification. Zero limit means that the size is not limited.
+//
+// Signature is a RFC6979 signature of the container ID concatenated with
+// the soft and hard limits encoded as 8 byte big-endian integers.
+// PublicKey contains the public key of the signer.
+// Token is optional and should be a stable marshaled SessionToken structure from
+// API.
+//
+// If the container doesn't exist, it panics with NotFoundError.
+func SetQuota(containerID []byte, softLimit, hardLimit int, signature interop.Signature,
+	publicKey interop.PublicKey, token []byte) {
+	ctx := storage.GetContext()
+	notaryDisabled := storage.Get(ctx, notaryDisabledKey).(bool)
+
+	if softLimit < 0 || hardLimit < 0 || (hardLimit != 0 && softLimit > hardLimit) {
+		panic(ErrInvalidQuota)
+	}
+
+	ownerID := getOwnerByID(ctx, containerID)
+	if ownerID == nil {
+		panic(NotFoundError)
+	}
+
+	if isRemoved(ctx, containerID) {
+		panic(RemovedError)
+	}
+
+	if notaryDisabled {
+		alphabet := common.AlphabetNodes()
+		nodeKey := common.InnerRingInvoker(alphabet)
+		if len(nodeKey) == 0 {
+			runtime.Notify("setQuota", containerID, softLimit, hardLimit, signature, publicKey, token)
+			return
+		}
+
+		threshold := len(alphabet)*2/3 + 1
+		id := common.InvokeID([]interface{}{containerID, softLimit, hardLimit, signature, publicKey, token}, []byte("setQuota"))
+
+		n := common.Vote(ctx, id, nodeKey)
+		if n < threshold {
+			return
+		}
+
+		common.RemoveVotes(ctx, id)
+	} else {
+		multiaddr := common.AlphabetAddress()
+		common.CheckAlphabetWitness(multiaddr)
+	}
+
+	common.SetSerialized(ctx, append([]byte(quotaPrefix), containerID...), containerQuota{
+		soft:  softLimit,
+		hard:  hardLimit,
+		sig:   signature,
+		pub:   publicKey,
+		token: token,
+	})
+
+	runtime.Log("container quota has been set")
+	runtime.Notify("SetQuotaSuccess", containerID, publicKey)
+}
+
+// ClearQuota method removes limits of the container size if it has been
+// invoked by Alphabet nodes of the Inner Ring. Otherwise, it produces
+// clearQuota notification.
+//
+// Signature is a RFC6979 signature of the container ID.
+// PublicKey contains the public key of the signer.
+// Token is optional and should be a stable marshaled SessionToken structure from
+// API.
+//
+// If the container doesn't exist, it panics with NotFoundError.
+func ClearQuota(containerID []byte, signature interop.Signature, publicKey interop.PublicKey, token []byte) {
+	ctx := storage.GetContext()
+	notaryDisabled := storage.Get(ctx, notaryDisabledKey).(bool)
+
+	ownerID := getOwnerByID(ctx, containerID)
+	if ownerID == nil {
+		panic(NotFoundError)
+	}
+
+	if isRemoved(ctx, containerID) {
+		panic(RemovedError)
+	}
+
+	key := append([]byte(quotaPrefix), containerID...)
+	if storage.Get(ctx, key) == nil {
+		return
+	}
+
+	if notaryDisabled {
+		alphabet := common.AlphabetNodes()
+		nodeKey := common.InnerRingInvoker(alphabet)
+		if len(nodeKey) == 0 {
+			runtime.Notify("clearQuota", containerID, signature, publicKey, token)
+			return
+		}
+
+		threshold := len(alphabet)*2/3 + 1
+		id := common.InvokeID([]interface{}{containerID, signature}, []byte("clearQuota"))
+
+		n := common.Vote(ctx, id, nodeKey)
+		if n < threshold {
+			return
+		}
+
+		common.RemoveVotes(ctx, id)
+	} else {
+		multiaddr := common.AlphabetAddress()
+		common.CheckAlphabetWitness(multiaddr)
+	}
+
+	storage.Delete(ctx, key)
+
+	runtime.Log("container quota has been cleared")
+	runtime.Notify("ClearQuotaSuccess", containerID, publicKey)
+}
+
+// Quota method returns a structure that contains soft and hard limits of
+// the container size, the signature, the public key of the quota setter and
+// a stable marshaled SessionToken structure if it was provided. It returns
+// Null if the quota is not set.
+//
+// If the container doesn't exist, it panics with NotFoundError.
+func Quota(containerID []byte) interface{} {
+	ctx := storage.GetReadOnlyContext()
+
+	ownerID := getOwnerByID(ctx, containerID)
+	if ownerID == nil {
+		panic(NotFoundError)
+	}
+
+	data := storage.Get(ctx, append([]byte(quotaPrefix), containerID...))
+	if data == nil {
+		return nil
+	}
+
+	return std.Deserialize(data.([]byte)).(containerQuota)
+}
+
+// QuotaBreaches method returns an array of structures that contain the epoch,
+// the biggest container size estimation of that epoch which exceeds the
+// container quota and whether the hard limit is exceeded, ordered by epoch.
+// Breaches are kept for UsageCleanupDelta epochs.
+//
+// If the container doesn't exist, it panics with NotFoundError.
+func QuotaBreaches(containerID []byte) []quotaBreach {
+	ctx := storage.GetReadOnlyContext()
+
+	ownerID := getOwnerByID(ctx, containerID)
+	if ownerID == nil {
+		panic(NotFoundError)
+	}
+
+	breaches := []quotaBreach{}
+
+	prefix := append([]byte(quotaBreachPrefix), containerID...)
+	it := storage.Find(ctx, prefix, storage.ValuesOnly|storage.DeserializeValues)
+	for iterator.Next(it) {
+		breaches = append(breaches, iterator.Value(it).(quotaBreach))
+	}
+
+	return breaches
+}
+
+// checkQuota records the breach and produces QuotaExceeded notification if
+// the container size estimation exceeds the container quota. Notification is
+// produced once per epoch for each limit.
+func checkQuota(ctx storage.Context, epoch int, cid []byte, size int) {
+	data := storage.Get(ctx, append([]byte(quotaPrefix), cid...))
+	if data == nil {
+		return
+	}
+
+	quota := std.Deserialize(data.([]byte)).(containerQuota)
+
+	hard := quota.hard != 0 && size > quota.hard
+	if !hard && (quota.soft == 0 || size <= quota.soft) {
+		return
+	}
+
+	prefix := append([]byte(quotaBreachPrefix), cid...)
+	key := append(prefix, common.EpochKey(epoch)...)
+
+	notify := true
+	old := storage.Get(ctx, key)
+	if old != nil {
+		breach := std.Deserialize(old.([]byte)).(quotaBreach)
+		if breach.size >= size {
+			return
+		}
+		notify = hard && !breach.hard
+	} else {
+		cleanupQuotaBreaches(ctx, prefix, epoch)
+	}
+
+	common.SetSerialized(ctx, key, quotaBreach{
+		epoch: epoch,
+		size:  size,
+		hard:  hard,
+	})
+
+	if notify {
+		runtime.Notify("QuotaExceeded", cid, epoch, size, hard)
+	}
+}
+
+// cleanupQuotaBreaches removes container quota breaches older than
+// UsageCleanupDelta epochs.
+func cleanupQuotaBreaches(ctx storage.Context, prefix []byte, epoch int) {
+	it := storage.Find(ctx, prefix, storage.KeysOnly|storage.RemovePrefix)
+	for iterator.Next(it) {
+		k := iterator.Value(it).([]byte)
+		if epoch-common.EpochFromKey(k) <= UsageCleanupDelta {
+			break
+		}
+
+		storage.Delete(ctx, append(prefix, k...))
+	}
+}
+
+// removeQuota deletes the quota and the quota breaches of the container.
+func removeQuota(ctx storage.Context, cid []byte) {
+	storage.Delete(ctx, append([]byte(quotaPrefix), cid...))
+
+	it := storage.Find(ctx, append([]byte(quotaBreachPrefix), cid...), storage.KeysOnly)
+	for iterator.Next(it) {
+		storage.Delete(ctx, iterator.Value(it).([]byte))
+	}
+}
@@ -0,0 +1,162 @@
+package netmap
+
+import (
+	"github.com/nspcc-dev/neo-go/pkg/interop"
+	"github.com/nspcc-dev/neo-go/pkg/interop/contract"
+	"github.com/nspcc-dev/neo-go/pkg/interop/convert"
+	"github.com/nspcc-dev/neo-go/pkg/interop/iterator"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/std"
+	"github.com/nspcc-dev/neo-go/pkg/interop/runtime"
+	"github.com/nspcc-dev/neo-go/pkg/interop/storage"
+	"github.com/nspcc-dev/neofs-contract/common"
+)
+
+const (
+	// AdmissionCheckKey is a key in netmap config which enables the check of
+	// the new candidates in the subnet contract: a candidate must be allowed
+	// in every non-zero subnet declared in its NodeInfo.
+	AdmissionCheckKey = "AdmissionCheck"
+	// AdmissionAllowlistKey is a key in netmap config which enables the check
+	// of the new candidates in the allowlist of netmap contract. It takes
+	// effect only if AdmissionCheckKey is enabled.
+	AdmissionAllowlistKey = "AdmissionAllowlist"
+	// SubnetContractKey is a key in netmap config which contains the script
+	// hash of the subnet contract used by the admission check.
+	SubnetContractKey = "SubnetContract"
+
+	// ErrNodeNotAllowed is thrown when the candidate is not allowed in some
+	// subnet declared in its NodeInfo.
+	ErrNodeNotAllowed = "node is not allowed in subnet"
+	// ErrNodeNotInAllowlist is thrown when the candidate is missing from
+	// the allowlist.
+	ErrNodeNotInAllowlist = "node is not in the allowlist"
+
+	allowlistPrefix = "allowlist_"
+
+	// subnetAttributePrefix is a prefix of NodeInfo attribute keys which
+	// declare node subnets, e.g. `__NEOFS__SUBNET_1` with `True` value.
+	subnetAttributePrefix = "__NEOFS__SUBNET_"
+	subnetAttributeTrue   = "True"
+	maxSubnetID           = 0xFFFFFFFF
+	maxSubnetIDLen        = 10 // decimal digits of maxSubnetID
+)
+
+// AddToAllowlist method adds the public key to the allowlist of the Storage
+// nodes which can join the network map, see AdmissionAllowlistKey. It can be
+// invoked only by Alphabet nodes.
+func AddToAllowlist(publicKey interop.PublicKey) {
+	if len(publicKey) != interop.PublicKeyCompressedLen {
+		panic("incorrect public key")
+	}
+
+	ctx := storage.GetContext()
+	id := common.InvokeID([]interface{}{publicKey}, []byte("allow"))
+	if !checkAlphabetInvoker(ctx, id) {
+		return
+	}
+
+	storage.Put(ctx, append([]byte(allowlistPrefix), publicKey...), publicKey)
+	runtime.Log("node has been added to the allowlist")
+}
+
+// RemoveFromAllowlist method removes the public key from the allowlist of
+// the Storage nodes. Candidates which are already in the network map candidate
+// list are not removed. It can be invoked only by Alphabet nodes.
+func RemoveFromAllowlist(publicKey interop.PublicKey) {
+	if len(publicKey) != interop.PublicKeyCompressedLen {
+		panic("incorrect public key")
+	}
+
+	ctx := storage.GetContext()
+	id := common.InvokeID([]interface{}{publicKey}, []byte("disallow"))
+	if !checkAlphabetInvoker(ctx, id) {
+		return
+	}
+
+	storage.Delete(ctx, append([]byte(allowlistPrefix), publicKey...))
+	runtime.Log("node has been removed from the allowlist")
+}
+
+// ListAllowlist method returns an iterator over public keys of the allowlist.
+func ListAllowlist() iterator.Iterator {
+	ctx := storage.GetReadOnlyContext()
+	return storage.Find(ctx, []byte(allowlistPrefix), storage.ValuesOnly)
+}
+
+// checkAdmission panics if the admission check is enabled and the node is
+// not allowed to join the network map.
+func checkAdmission(ctx storage.Context, ni nodeInfo) {
+	if !getConfigBool(ctx, AdmissionCheckKey) {
+		return
+	}
+
+	if getConfigBool(ctx, AdmissionAllowlistKey) {
+		if storage.Get(ctx, append([]byte(allowlistPrefix), ni.publicKey...)) == nil {
+			panic(ErrNodeNotInAllowlist)
+		}
+	}
+
+	var subnetContract interop.Hash160
+
+	for i := range ni.attributes {
+		num := subnetFromAttribute(ni.attributes[i])
+		if num <= 0 {
+			continue
+		}
+
+		if subnetContract == nil {
+			cfg := getConfig(ctx, []byte(SubnetContractKey))
+			if cfg == nil {
+				panic("subnet contract is not set")
+			}
+			subnetContract = cfg.(interop.Hash160)
+		}
+
+		allowed := contract.Call(subnetContract, "nodeAllowed", contract.ReadOnly,
+			subnetID(num), ni.publicKey).(bool)
+		if !allowed {
+			panic(ErrNodeNotAllowed)
+		}
+	}
+}
+
+// subnetFromAttribute returns the number of the subnet declared by the node
+// attribute. It returns -1 if the attribute doesn't declare node subnet and
+// panics if the subnet number is not a valid decimal number.
+func subnetFromAttribute(attr nodeAttribute) int {
+	key := []byte(attr.key)
+	if len(key) <= len(subnetAttributePrefix) || std.MemorySearch(key, []byte(subnetAttributePrefix)) != 0 {
+		return -1
+	}
+	if attr.value != subnetAttributeTrue {
+		return -1
+	}
+
+	suffix := key[len(subnetAttributePrefix):]
+	if len(suffix) > maxSubnetIDLen {
+		panic(ErrInvalidNodeAttribute)
+	}
+	for i := range suffix {
+		if suffix[i] < '0' || suffix[i] > '9' {
+			panic(ErrInvalidNodeAttribute)
+		}
+	}
+
+	num := std.Atoi(string(suffix), 10)
+	if num > maxSubnetID {
+		panic(ErrInvalidNodeAttribute)
+	}
+
+	return num
+}
+
+// subnetID returns a stable marshaled refs.SubnetID structure
+// with the specified number.
+func subnetID(num int) []byte {
+	id := []byte{0x0D, 0, 0, 0, 0} // fixed32 field 1
+	le := convert.ToBytes(num)
+	for i := 0; i < len(le) && i < 4; i++ {
+		id[1+i] = le[i]
+	}
+	return id
+}
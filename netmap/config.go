@@ -0,0 +1,341 @@
+package netmap
+
+import (
+	"github.com/nspcc-dev/neo-go/pkg/interop"
+	"github.com/nspcc-dev/neo-go/pkg/interop/iterator"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/crypto"
+	"github.com/nspcc-dev/neo-go/pkg/interop/runtime"
+	"github.com/nspcc-dev/neo-go/pkg/interop/storage"
+	"github.com/nspcc-dev/neofs-contract/common"
+)
+
+type (
+	configType int
+
+	// configSchemaEntry describes a well-known NeoFS configuration value.
+	// Nil min or max means that the value is not limited from that side.
+	// Immutable values can't be changed once they are set.
+	configSchemaEntry struct {
+		key     string
+		typ     configType
+		min     interface{}
+		max     interface{}
+		mutable bool
+	}
+
+	// configChange is a NeoFS configuration value change scheduled
+	// to be applied in the specified epoch.
+	configChange struct {
+		epoch int
+		key   []byte
+		val   []byte
+	}
+)
+
+const (
+	_ configType = iota
+	IntegerConfigType
+	BooleanConfigType
+	StringConfigType
+	Hash160ConfigType
+)
+
+const (
+	// UnknownConfigAllowedKey is a key in netmap config which allows setting
+	// configuration values which are not described in the config schema.
+	UnknownConfigAllowedKey = "UnknownConfigAllowed"
+
+	// ErrUnknownConfigKey is thrown when the config key is not described in the
+	// config schema and unknown keys are not allowed.
+	ErrUnknownConfigKey = "unknown config key"
+	// ErrInvalidConfigValue is thrown when the config value doesn't match the
+	// type or range from the config schema.
+	ErrInvalidConfigValue = "invalid config value"
+	// ErrImmutableConfigKey is thrown on an attempt to change an immutable
+	// config value which is already set.
+	ErrImmutableConfigKey = "immutable config key"
+
+	// Scheduled change keys are prefix + epoch + ripemd160(key), history
+	// keys are prefix + ripemd160(key) + epoch.
+	configScheduledPrefix = "cfgScheduled_"
+	configHistoryPrefix   = "cfgHistory_"
+)
+
+// configSchema returns the schema of the well-known NeoFS configuration values.
+func configSchema() []configSchemaEntry {
+	return []configSchemaEntry{
+		{key: "MaxObjectSize", typ: IntegerConfigType, min: 1, mutable: true},
+		{key: "BasicIncomeRate", typ: IntegerConfigType, min: 0, mutable: true},
+		{key: "AuditFee", typ: IntegerConfigType, min: 0, mutable: true},
+		{key: "EpochDuration", typ: IntegerConfigType, min: 1, mutable: true},
+		{key: "ContainerFee", typ: IntegerConfigType, min: 0, mutable: true},
+		{key: "ContainerAliasFee", typ: IntegerConfigType, min: 0, mutable: true},
+		{key: "ContainerRemovalGracePeriod", typ: IntegerConfigType, min: 0, mutable: true},
+		{key: "ContainerOwnerUsageRetention", typ: IntegerConfigType, min: 0, mutable: true},
+		{key: "EigenTrustIterations", typ: IntegerConfigType, min: 1, mutable: true},
+		{key: "EigenTrustAlpha", typ: StringConfigType, mutable: true},
+		{key: "InnerRingCandidateFee", typ: IntegerConfigType, min: 0, mutable: true},
+		{key: "WithdrawFee", typ: IntegerConfigType, min: 0, mutable: true},
+		{key: "HomomorphicHashingDisabled", typ: BooleanConfigType, min: 0, max: 1, mutable: false},
+		{key: SnapshotArchiveRetentionKey, typ: IntegerConfigType, min: 0, mutable: true},
+		{key: CandidateExpirationKey, typ: IntegerConfigType, min: 0, mutable: true},
+		{key: UnknownConfigAllowedKey, typ: BooleanConfigType, min: 0, max: 1, mutable: true},
+		{key: AdmissionCheckKey, typ: BooleanConfigType, min: 0, max: 1, mutable: true},
+		{key: AdmissionAllowlistKey, typ: BooleanConfigType, min: 0, max: 1, mutable: true},
+		{key: SubnetContractKey, typ: Hash160ConfigType, mutable: true},
+	}
+}
+
+// ConfigSchema method returns an array of structures that describe well-known
+// NeoFS configuration values: key, value type (integer: 1, boolean: 2,
+// string: 3, hash160: 4), minimal and maximal values (Null if not limited)
+// and whether the value can be changed once it is set.
+func ConfigSchema() []configSchemaEntry {
+	return configSchema()
+}
+
+// ScheduleConfig method schedules NeoFS runtime configuration value change
+// which is applied by NewEpoch method of the specified epoch. It can be invoked
+// only by Alphabet nodes. The value is checked the same way as in SetConfig
+// method. Epoch must be bigger than the current one. A change which is already
+// scheduled for the same key and epoch is replaced.
+func ScheduleConfig(id, key, val []byte, epoch int) {
+	ctx := storage.GetContext()
+
+	checkConfig(ctx, key, val)
+
+	currentEpoch := storage.Get(ctx, snapshotEpoch).(int)
+	if epoch <= currentEpoch {
+		panic("invalid epoch")
+	}
+
+	if !checkAlphabetInvoker(ctx, id) {
+		return
+	}
+
+	common.SetSerialized(ctx, scheduledConfigKey(epoch, key), configChange{
+		epoch: epoch,
+		key:   key,
+		val:   val,
+	})
+
+	runtime.Log("configuration change has been scheduled")
+}
+
+// CancelConfig method cancels NeoFS runtime configuration value change
+// scheduled for the specified epoch. It can be invoked only by Alphabet nodes.
+func CancelConfig(id, key []byte, epoch int) {
+	ctx := storage.GetContext()
+	if !checkAlphabetInvoker(ctx, id) {
+		return
+	}
+
+	storageKey := scheduledConfigKey(epoch, key)
+	if storage.Get(ctx, storageKey) == nil {
+		panic("config change not found")
+	}
+
+	storage.Delete(ctx, storageKey)
+
+	runtime.Log("configuration change has been canceled")
+}
+
+// ListScheduledConfig method returns an array of structures that contain
+// epoch, key and value of all scheduled NeoFS configuration changes ordered
+// by epoch.
+func ListScheduledConfig() []configChange {
+	ctx := storage.GetReadOnlyContext()
+
+	changes := []configChange{}
+
+	it := storage.Find(ctx, []byte(configScheduledPrefix), storage.ValuesOnly|storage.DeserializeValues)
+	for iterator.Next(it) {
+		changes = append(changes, iterator.Value(it).(configChange))
+	}
+
+	return changes
+}
+
+// ConfigAt method returns the value of NeoFS runtime configuration at the end
+// of the specified epoch, i.e. the latest value set in that epoch or before it.
+// It returns Null if there is no history of the value up to the specified
+// epoch, e.g. the value has been set later.
+func ConfigAt(key []byte, epoch int) interface{} {
+	ctx := storage.GetReadOnlyContext()
+
+	var val interface{}
+
+	prefix := append([]byte(configHistoryPrefix), crypto.Ripemd160(key)...)
+	it := storage.Find(ctx, prefix, storage.RemovePrefix)
+	for iterator.Next(it) {
+		kv := iterator.Value(it).(struct {
+			key []byte
+			val []byte
+		})
+		if common.EpochFromKey(kv.key) > epoch {
+			break
+		}
+
+		val = kv.val
+	}
+
+	return val
+}
+
+// checkAlphabetInvoker checks that the method is invoked by the Alphabet.
+// In notary disabled environment, it returns false until the vote with
+// the specified id is finished.
+func checkAlphabetInvoker(ctx storage.Context, id []byte) bool {
+	notaryDisabled := storage.Get(ctx, notaryDisabledKey).(bool)
+	if !notaryDisabled {
+		common.CheckAlphabetWitness(common.AlphabetAddress())
+		return true
+	}
+
+	alphabet := common.AlphabetNodes()
+	nodeKey := common.InnerRingInvoker(alphabet)
+	if len(nodeKey) == 0 {
+		panic("invoked by non inner ring node")
+	}
+
+	threshold := len(alphabet)*2/3 + 1
+
+	n := common.Vote(ctx, id, nodeKey)
+	if n < threshold {
+		return false
+	}
+
+	common.RemoveVotes(ctx, id)
+	return true
+}
+
+// applyScheduledConfig applies configuration changes scheduled up to the
+// specified epoch. Changes of immutable values which have been set
+// after the change was scheduled are dropped.
+func applyScheduledConfig(ctx storage.Context, epoch int) {
+	it := storage.Find(ctx, []byte(configScheduledPrefix), storage.ValuesOnly|storage.DeserializeValues)
+	for iterator.Next(it) {
+		change := iterator.Value(it).(configChange)
+		if change.epoch > epoch {
+			break
+		}
+
+		storage.Delete(ctx, scheduledConfigKey(change.epoch, change.key))
+
+		entry := getConfigSchemaEntry(change.key)
+		if entry != nil && !canChangeConfig(ctx, entry.(configSchemaEntry), change.key, change.val) {
+			runtime.Log("scheduled change of immutable configuration is dropped")
+			continue
+		}
+
+		setConfig(ctx, change.key, change.val)
+	}
+}
+
+func scheduledConfigKey(epoch int, key []byte) []byte {
+	storageKey := append([]byte(configScheduledPrefix), common.EpochKey(epoch)...)
+	return append(storageKey, crypto.Ripemd160(key)...)
+}
+
+// putConfigHistory records the value of NeoFS runtime configuration
+// in the specified epoch.
+func putConfigHistory(ctx storage.Context, key []byte, val interface{}, epoch int) {
+	storageKey := append([]byte(configHistoryPrefix), crypto.Ripemd160(key)...)
+	storage.Put(ctx, append(storageKey, common.EpochKey(epoch)...), val)
+}
+
+// migrateConfigHistory records the current values of NeoFS runtime
+// configuration which have no history yet.
+func migrateConfigHistory(ctx storage.Context) {
+	epoch := storage.Get(ctx, snapshotEpoch).(int)
+
+	it := storage.Find(ctx, configPrefix, storage.RemovePrefix)
+	for iterator.Next(it) {
+		kv := iterator.Value(it).(struct {
+			key []byte
+			val []byte
+		})
+
+		prefix := append([]byte(configHistoryPrefix), crypto.Ripemd160(kv.key)...)
+		hist := storage.Find(ctx, prefix, storage.KeysOnly)
+		if !iterator.Next(hist) {
+			putConfigHistory(ctx, kv.key, kv.val, epoch)
+		}
+	}
+}
+
+// getConfigSchemaEntry returns the schema entry of the config key or nil
+// if the key is not described in the config schema.
+func getConfigSchemaEntry(key []byte) interface{} {
+	schema := configSchema()
+	for i := range schema {
+		if schema[i].key == string(key) {
+			return schema[i]
+		}
+	}
+
+	return nil
+}
+
+// checkConfig panics if the value can't be set for the specified key.
+func checkConfig(ctx storage.Context, key []byte, val interface{}) {
+	entry := getConfigSchemaEntry(key)
+	if entry == nil {
+		allowed := getConfig(ctx, []byte(UnknownConfigAllowedKey))
+		if allowed == nil || !allowed.(bool) {
+			panic(ErrUnknownConfigKey)
+		}
+		return
+	}
+
+	checkConfigValue(entry.(configSchemaEntry), val)
+
+	if !canChangeConfig(ctx, entry.(configSchemaEntry), key, val) {
+		panic(ErrImmutableConfigKey)
+	}
+}
+
+// canChangeConfig returns false if the value is immutable and is already
+// set to another value.
+func canChangeConfig(ctx storage.Context, entry configSchemaEntry, key []byte, val interface{}) bool {
+	if entry.mutable {
+		return true
+	}
+
+	old := getConfig(ctx, key)
+	return old == nil || sameConfigValue(entry.typ, old, val)
+}
+
+// checkConfigValue panics if the value doesn't match the schema entry.
+// Integer and boolean values are compared as numbers, so they can be
+// passed both as integers and as byte arrays.
+func checkConfigValue(entry configSchemaEntry, val interface{}) {
+	if entry.typ == StringConfigType {
+		if len(val.([]byte)) == 0 {
+			panic(ErrInvalidConfigValue)
+		}
+		return
+	}
+	if entry.typ == Hash160ConfigType {
+		if len(val.([]byte)) != interop.Hash160Len {
+			panic(ErrInvalidConfigValue)
+		}
+		return
+	}
+
+	n := val.(int)
+	if entry.min != nil && n < entry.min.(int) {
+		panic(ErrInvalidConfigValue)
+	}
+	if entry.max != nil && n > entry.max.(int) {
+		panic(ErrInvalidConfigValue)
+	}
+}
+
+func sameConfigValue(typ configType, a, b interface{}) bool {
+	if typ == StringConfigType || typ == Hash160ConfigType {
+		return common.BytesEqual(a.([]byte), b.([]byte))
+	}
+
+	return a.(int) == b.(int)
+}
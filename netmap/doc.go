@@ -15,8 +15,8 @@ a bootstrap request by invoking AddPeer method.
       type: ByteArray
 
 UpdateState notification. This notification is produced when a Storage node wants
-to change its state (go offline) by invoking UpdateState method. Supported
-states: (2) -- offline.
+to change its state (go offline or into maintenance) by invoking UpdateState
+method. Supported states: (2) -- offline, (3) -- maintenance.
 
   UpdateState
     - name: state
@@ -24,11 +24,52 @@ states: (2) -- offline.
     - name: publicKey
       type: PublicKey
 
+RotateNodeKey notification. This notification is produced when a Storage node
+wants to move to a new public key by invoking RotateNodeKey method in notary
+disabled environment.
+
+  RotateNodeKey
+    - name: oldKey
+      type: PublicKey
+    - name: nodeInfo
+      type: ByteArray
+
+CandidateExpired notification. This notification is produced when a Storage
+node candidate is removed from the network map candidate list by NewEpoch method
+because it has not been added again during the number of epochs set in
+CandidateExpiration config value.
+
+  CandidateExpired
+    - name: publicKey
+      type: PublicKey
+
+NetmapChanged notification. This notification is produced when a new epoch is
+applied in the network by invoking NewEpoch method. It contains public keys of
+the Storage nodes which joined and left the network map in the new epoch.
+
+  NetmapChanged
+    - name: epoch
+      type: Integer
+    - name: added
+      type: Array
+    - name: removed
+      type: Array
+
 NewEpoch notification. This notification is produced when a new epoch is applied
 in the network by invoking NewEpoch method.
 
   NewEpoch
     - name: epoch
       type: Integer
+
+NewEpochSnapshotHash notification. This notification is produced together with
+NewEpoch notification. It contains the hash of the new network map, see
+SnapshotHash method.
+
+  NewEpochSnapshotHash
+    - name: epoch
+      type: Integer
+    - name: hash
+      type: Hash256
 */
 package netmap
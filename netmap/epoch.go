@@ -0,0 +1,138 @@
+package netmap
+
+import (
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/ledger"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/std"
+	"github.com/nspcc-dev/neo-go/pkg/interop/runtime"
+	"github.com/nspcc-dev/neo-go/pkg/interop/storage"
+	"github.com/nspcc-dev/neofs-contract/common"
+)
+
+// epochTiming contains the index of the last block persisted before the epoch
+// was applied (the same as returned by LastEpochBlock) and the timestamp
+// (in milliseconds) of the block which has applied the epoch.
+type epochTiming struct {
+	epoch     int
+	block     int
+	timestamp int
+}
+
+const (
+	// Timing records are kept in the order the epochs are applied, so they
+	// are sorted by epoch, block and timestamp. Epochs are not sequential,
+	// so each epoch is mapped to its record number.
+	epochTimingPrefix   = "epochTiming_"
+	epochTimingCountKey = "epochTimingCount"
+	epochRecordPrefix   = "epochRecord_"
+)
+
+// EpochTiming method returns a structure that contains the epoch number, the
+// block index and the timestamp (in milliseconds) of the specified epoch.
+// The block index is the same as returned by LastEpochBlock method during that
+// epoch, i.e. the index of the last block persisted before the epoch was
+// applied. The timestamp is the one of the block which has applied the epoch.
+// It returns Null for epochs which have not been applied or were applied before
+// the timing records have been kept.
+func EpochTiming(epoch int) interface{} {
+	ctx := storage.GetReadOnlyContext()
+	num := storage.Get(ctx, append([]byte(epochRecordPrefix), common.EpochKey(epoch)...))
+	if num == nil {
+		return nil
+	}
+
+	return getEpochTiming(ctx, num.(int))
+}
+
+// EpochByBlock method returns the number of the epoch which was current at
+// the block with the specified index. It returns Null if the block precedes
+// all epoch timing records.
+func EpochByBlock(block int) interface{} {
+	ctx := storage.GetReadOnlyContext()
+	return searchEpoch(ctx, block, false)
+}
+
+// EpochByTime method returns the number of the epoch which was current at
+// the specified timestamp (in milliseconds). It returns Null if the timestamp
+// precedes all epoch timing records.
+func EpochByTime(timestamp int) interface{} {
+	ctx := storage.GetReadOnlyContext()
+	return searchEpoch(ctx, timestamp, true)
+}
+
+// putEpochTiming records the timing of the epoch applied after the specified
+// block at the specified time.
+func putEpochTiming(ctx storage.Context, epoch, block, timestamp int) {
+	var num int
+	count := storage.Get(ctx, epochTimingCountKey)
+	if count != nil {
+		num = count.(int)
+	}
+
+	common.SetSerialized(ctx, append([]byte(epochTimingPrefix), common.EpochKey(num)...), epochTiming{
+		epoch:     epoch,
+		block:     block,
+		timestamp: timestamp,
+	})
+	storage.Put(ctx, append([]byte(epochRecordPrefix), common.EpochKey(epoch)...), num)
+	storage.Put(ctx, epochTimingCountKey, num+1)
+}
+
+// migrateEpochTiming records the timing of the current epoch if there are no
+// timing records yet.
+func migrateEpochTiming(ctx storage.Context) {
+	if storage.Get(ctx, epochTimingCountKey) != nil {
+		return
+	}
+
+	epoch := storage.Get(ctx, snapshotEpoch).(int)
+	block := storage.Get(ctx, snapshotBlockKey).(int)
+
+	// the epoch has been applied by the next block, it is not persisted yet
+	// if the contract is updated in the same block
+	timestamp := runtime.GetTime()
+	if block < ledger.CurrentIndex() {
+		b := ledger.GetBlock(block + 1)
+		timestamp = b.Timestamp
+	}
+
+	putEpochTiming(ctx, epoch, block, timestamp)
+}
+
+func getEpochTiming(ctx storage.Context, num int) epochTiming {
+	data := storage.Get(ctx, append([]byte(epochTimingPrefix), common.EpochKey(num)...))
+	return std.Deserialize(data.([]byte)).(epochTiming)
+}
+
+// searchEpoch returns the last epoch which was applied at the specified block
+// index or timestamp or before it.
+func searchEpoch(ctx storage.Context, value int, byTime bool) interface{} {
+	count := storage.Get(ctx, epochTimingCountKey)
+	if count == nil {
+		return nil
+	}
+
+	var (
+		found interface{}
+		lo    = 0
+		hi    = count.(int) - 1
+	)
+
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		t := getEpochTiming(ctx, mid)
+
+		v := t.block
+		if byTime {
+			v = t.timestamp
+		}
+
+		if v <= value {
+			found = t.epoch
+			lo = mid + 1
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	return found
+}
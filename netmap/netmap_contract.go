@@ -23,12 +23,35 @@ type (
 		state nodeState
 	}
 
+	// snapshotNode is a network map snapshot entry. It is returned only by
+	// the iterator-based snapshot methods and SnapshotNodeByEpoch, other
+	// methods return storageNode.
+	snapshotNode struct {
+		info  []byte
+		state nodeState
+	}
+
 	nodeState int
 
+	// netmapDiff contains public keys of the storage nodes which
+	// were added to and removed from the network map.
+	netmapDiff struct {
+		added   []interop.PublicKey
+		removed []interop.PublicKey
+	}
+
 	record struct {
 		key []byte
 		val []byte
 	}
+
+	// nodeStats contains the first and the last epochs when the storage node
+	// was online in the network map and the number of such epochs.
+	nodeStats struct {
+		firstEpoch int
+		lastEpoch  int
+		count      int
+	}
 )
 
 const (
@@ -44,6 +67,39 @@ const (
 	snapshotEpoch        = "snapshotEpoch"
 	snapshotBlockKey     = "snapshotBlock"
 
+	// SnapshotArchiveRetentionKey is a key in netmap config which contains
+	// the number of the last epochs which network map snapshots are kept
+	// in the archive. Archive is disabled if the value is missing or zero.
+	SnapshotArchiveRetentionKey = "SnapshotArchiveRetention"
+	archiveEpochPrefix          = "archiveEpoch_"
+	archiveNodePrefix           = "archiveNode_"
+
+	// CandidateExpirationKey is a key in netmap config which contains the number
+	// of epochs after which an online candidate is removed from the network map
+	// candidate list if it has not been added again. Candidates never expire
+	// if the value is missing or zero.
+	CandidateExpirationKey = "CandidateExpiration"
+	bootstrapEpochPrefix   = "bootstrap_"
+
+	// Attribute index keys are prefix + ripemd160(attribute) + public key,
+	// see attributeID. Node keys contain the list of indexed attributes
+	// of the candidate.
+	candidateAttrPrefix = "attrCnd_"
+	netmapAttrPrefix    = "attrNm_"
+	nodeAttrPrefix      = "attrNode_"
+	// attributeIndexKey is set when the attribute index contains all the
+	// candidates.
+	attributeIndexKey = "attributeIndex"
+
+	nodeStatsPrefix = "nodeStats_"
+
+	// Snapshot hashes are kept for all epochs, unlike snapshots.
+	snapshotHashPrefix = "netmapHash_"
+
+	// Rotated storage node keys are linked in both directions.
+	nextNodeKeyPrefix     = "keyNext_"
+	previousNodeKeyPrefix = "keyPrev_"
+
 	containerContractKey = "containerScriptHash"
 	balanceContractKey   = "balanceScriptHash"
 
@@ -55,6 +111,7 @@ const (
 	_ nodeState = iota
 	OnlineState
 	OfflineState
+	MaintenanceState
 )
 
 var (
@@ -63,6 +120,12 @@ var (
 )
 
 // _deploy function sets up initial list of inner ring public keys.
+//
+// Configuration values are checked the same way as in SetConfig method.
+// Unknown keys are allowed if UnknownConfigAllowed value is set to true in
+// the same configuration, regardless of the order of the keys. On contract
+// update unknown keys are always allowed, so that the configuration existing
+// before the check was introduced can be passed as is.
 func _deploy(data interface{}, isUpdate bool) {
 	ctx := storage.GetContext()
 
@@ -80,15 +143,32 @@ func _deploy(data interface{}, isUpdate bool) {
 		panic("bad configuration")
 	}
 
+	unknownAllowed := isUpdate
+	for i := 0; i < ln/2; i++ {
+		if !isUpdate && common.BytesEqual(args.config[i*2], []byte(UnknownConfigAllowedKey)) {
+			var val interface{} = args.config[i*2+1]
+			unknownAllowed = val.(bool)
+		}
+	}
+
 	for i := 0; i < ln/2; i++ {
 		key := args.config[i*2]
 		val := args.config[i*2+1]
 
+		if getConfigSchemaEntry(key) != nil {
+			checkConfig(ctx, key, val)
+		} else if !unknownAllowed {
+			panic(ErrUnknownConfigKey)
+		}
 		setConfig(ctx, key, val)
 	}
 
 	if isUpdate {
 		common.CheckVersion(args.version)
+		migrateCandidates(ctx)
+		migrateSnapshots(ctx)
+		migrateConfigHistory(ctx)
+		migrateEpochTiming(ctx)
 		return
 	}
 
@@ -100,12 +180,10 @@ func _deploy(data interface{}, isUpdate bool) {
 	storage.Put(ctx, snapshotCountKey, DefaultSnapshotCount)
 	storage.Put(ctx, snapshotEpoch, 0)
 	storage.Put(ctx, snapshotBlockKey, 0)
-
-	prefix := []byte(snapshotKeyPrefix)
-	for i := 0; i < DefaultSnapshotCount; i++ {
-		common.SetSerialized(ctx, append(prefix, byte(i)), []storageNode{})
-	}
 	storage.Put(ctx, snapshotCurrentIDKey, 0)
+	storage.Put(ctx, attributeIndexKey, true)
+	genesis := ledger.GetBlock(0)
+	putEpochTiming(ctx, 0, 0, genesis.Timestamp)
 
 	storage.Put(ctx, balanceContractKey, args.addrBalance)
 	storage.Put(ctx, containerContractKey, args.addrContainer)
@@ -191,6 +269,9 @@ func UpdateInnerRing(keys []interop.PublicKey) {
 
 // AddPeerIR method tries to add a new candidate to the network map.
 // It should only be invoked in notary-enabled environment by the alphabet.
+//
+// NodeInfo argument is validated and checked by the admission policy the same
+// way as in AddPeer method.
 func AddPeerIR(nodeInfo []byte) {
 	ctx := storage.GetContext()
 	notaryDisabled := storage.Get(ctx, notaryDisabledKey).(bool)
@@ -200,10 +281,12 @@ func AddPeerIR(nodeInfo []byte) {
 
 	common.CheckAlphabetWitness(common.AlphabetAddress())
 
-	addToNetmap(ctx, storageNode{info: nodeInfo})
+	ni := parseNodeInfo(nodeInfo)
+	publicKey := ni.publicKey
+	checkAdmission(ctx, ni)
 
-	publicKey := nodeInfo[2:35] // V2 format: offset:2, len:33
-	runtime.Notify("AddPeerSuccess", interop.PublicKey(publicKey))
+	addToNetmap(ctx, storageNode{info: nodeInfo}, ni.attributes)
+	runtime.Notify("AddPeerSuccess", publicKey)
 }
 
 // AddPeer method adds a new candidate to the next network map if it was invoked
@@ -212,7 +295,15 @@ func AddPeerIR(nodeInfo []byte) {
 //
 // If the candidate already exists, its info is updated.
 // NodeInfo argument contains a stable marshaled version of netmap.NodeInfo
-// structure.
+// structure. The method panics if the structure is malformed: the public key
+// is not the first field or is not a compressed public key, there are no
+// network addresses or some attribute lacks key or value.
+//
+// If AdmissionCheck config value is enabled, the candidate must be allowed in
+// every non-zero subnet declared in NodeInfo by subnet contract nodeAllowed
+// method. If AdmissionAllowlist config value is enabled too, the candidate
+// must also be in the allowlist, see AddToAllowlist method. Public keys which
+// have been rotated by RotateNodeKey method are rejected.
 func AddPeer(nodeInfo []byte) {
 	ctx := storage.GetContext()
 	notaryDisabled := storage.Get(ctx, notaryDisabledKey).(bool)
@@ -227,8 +318,10 @@ func AddPeer(nodeInfo []byte) {
 		nodeKey = common.InnerRingInvoker(alphabet)
 	}
 
-	// V2 format
-	publicKey := nodeInfo[2:35] // offset:2, len:33
+	ni := parseNodeInfo(nodeInfo)
+	publicKey := ni.publicKey
+	checkNotRotated(ctx, publicKey)
+	checkAdmission(ctx, ni)
 
 	// If notary is enabled or caller is not an alphabet node,
 	// just emit the notification for alphabet.
@@ -257,8 +350,8 @@ func AddPeer(nodeInfo []byte) {
 		common.RemoveVotes(ctx, id)
 	}
 
-	addToNetmap(ctx, candidate)
-	runtime.Notify("AddPeerSuccess", interop.PublicKey(publicKey))
+	addToNetmap(ctx, candidate, ni.attributes)
+	runtime.Notify("AddPeerSuccess", publicKey)
 }
 
 // UpdateState method updates the state of a node from the network map candidate list.
@@ -274,8 +367,10 @@ func AddPeer(nodeInfo []byte) {
 // | notary \ Signer | Storage node | Alphabet | Both                  |
 // | ENABLED         | FAIL         | FAIL     | OK                    |
 // | DISABLED        | NOTIFICATION | OK       | OK (same as alphabet) |
-// State argument defines node state. The only supported state now is (2) --
-// offline state. Node is removed from the network map candidate list.
+// State argument defines node state. Supported states are (2) -- offline and
+// (3) -- maintenance. Offline node is removed from the network map candidate
+// list. Maintenance node stays in the candidate list and gets into the next
+// network map snapshot with maintenance state.
 //
 // Method panics when invoked with unsupported states.
 func UpdateState(state int, publicKey interop.PublicKey) {
@@ -312,14 +407,7 @@ func UpdateState(state int, publicKey interop.PublicKey) {
 		common.CheckAlphabetWitness(common.AlphabetAddress())
 	}
 
-	switch nodeState(state) {
-	case OfflineState:
-		removeFromNetmap(ctx, publicKey)
-		runtime.Log("remove storage node from the network map")
-	default:
-		panic("unsupported state")
-	}
-
+	updateCandidateState(ctx, publicKey, nodeState(state))
 	runtime.Notify("UpdateStateSuccess", publicKey, state)
 }
 
@@ -334,24 +422,105 @@ func UpdateStateIR(state nodeState, publicKey interop.PublicKey) {
 
 	common.CheckAlphabetWitness(common.AlphabetAddress())
 
-	switch state {
-	case OfflineState:
-		removeFromNetmap(ctx, publicKey)
-	default:
-		panic("unsupported state")
-	}
+	updateCandidateState(ctx, publicKey, state)
 	runtime.Notify("UpdateStateSuccess", publicKey, state)
 }
 
+// RotateNodeKey method moves a node from the network map candidate list to
+// a new public key. NodeInfo argument contains a stable marshaled version of
+// netmap.NodeInfo structure with the new public key, it is validated and
+// checked by the admission policy the same way as in AddPeer method. The
+// candidate keeps its state and participation statistics. The link between
+// the old and the new key is recorded, see PreviousNodeKey and NextNodeKey
+// methods. A key can't be used again once it has been rotated: neither
+// AddPeer nor AddPeerIR nor RotateNodeKey accept it.
+//
+// For notary-ENABLED environment, tx must be signed by both old and new
+// node keys and alphabet.
+//
+// For notary-DISABLED environment, the behaviour depends on who signed the
+// transaction:
+// 1. If it was signed by alphabet, go into voting.
+// 2. If it was signed by both old and new node keys, emit `RotateNodeKey`
+// notification.
+// 3. Fail in any other case.
+//
+// It produces RotateNodeKeySuccess notification when the key is rotated.
+func RotateNodeKey(oldKey interop.PublicKey, nodeInfo []byte) {
+	if len(oldKey) != interop.PublicKeyCompressedLen {
+		panic("incorrect public key")
+	}
+
+	ctx := storage.GetContext()
+	notaryDisabled := storage.Get(ctx, notaryDisabledKey).(bool)
+
+	ni := parseNodeInfo(nodeInfo)
+	newKey := ni.publicKey
+	checkAdmission(ctx, ni)
+
+	if notaryDisabled {
+		alphabet := common.AlphabetNodes()
+		nodeKey := common.InnerRingInvoker(alphabet)
+
+		// If caller is not an alphabet node,
+		// just emit the notification for alphabet.
+		if len(nodeKey) == 0 {
+			common.CheckWitness(oldKey)
+			common.CheckWitness(newKey)
+			runtime.Notify("RotateNodeKey", oldKey, nodeInfo)
+			return
+		}
+
+		threshold := len(alphabet)*2/3 + 1
+		id := common.InvokeID([]interface{}{oldKey, nodeInfo}, []byte("rotate"))
+
+		n := common.Vote(ctx, id, nodeKey)
+		if n < threshold {
+			return
+		}
+
+		common.RemoveVotes(ctx, id)
+	} else {
+		common.CheckWitness(oldKey)
+		common.CheckWitness(newKey)
+		common.CheckAlphabetWitness(common.AlphabetAddress())
+	}
+
+	rotateNodeKey(ctx, oldKey, storageNode{info: nodeInfo}, ni.attributes)
+	runtime.Notify("RotateNodeKeySuccess", oldKey, newKey)
+}
+
+// PreviousNodeKey method returns the public key which the specified Storage
+// node key replaced by RotateNodeKey method. It returns Null if the key
+// hasn't replaced any other key.
+func PreviousNodeKey(publicKey interop.PublicKey) interface{} {
+	ctx := storage.GetReadOnlyContext()
+	return storage.Get(ctx, append([]byte(previousNodeKeyPrefix), publicKey...))
+}
+
+// NextNodeKey method returns the public key which replaced the specified
+// Storage node key by RotateNodeKey method. It returns Null if the key
+// hasn't been rotated.
+func NextNodeKey(publicKey interop.PublicKey) interface{} {
+	ctx := storage.GetReadOnlyContext()
+	return storage.Get(ctx, append([]byte(nextNodeKeyPrefix), publicKey...))
+}
+
 // NewEpoch method changes the epoch number up to the provided epochNum argument. It can
 // be invoked only by Alphabet nodes. If provided epoch number is less than the
 // current epoch number or equals it, the method throws panic.
 //
-// When epoch number is updated, the contract sets storage node candidates as the current
+// When epoch number is updated, the contract removes expired candidates (see
+// CandidateExpirationKey) and sets storage node candidates as the current
 // network map. The contract also invokes NewEpoch method on Balance and Container
 // contracts.
 //
-// It produces NewEpoch notification.
+// Configuration changes scheduled up to the new epoch are applied before the
+// network map is updated.
+//
+// It produces NetmapChanged notification with public keys of the nodes which
+// joined and left the network map, NewEpochSnapshotHash notification with the
+// hash of the new network map (see SnapshotHash) and NewEpoch notification.
 func NewEpoch(epochNum int) {
 	ctx := storage.GetContext()
 	notaryDisabled := storage.Get(ctx, notaryDisabledKey).(bool)
@@ -389,24 +558,30 @@ func NewEpoch(epochNum int) {
 		panic("invalid epoch") // ignore invocations with invalid epoch
 	}
 
-	dataOnlineState := filterNetmap(ctx, OnlineState)
-
 	runtime.Log("process new epoch")
 
 	// todo: check if provided epoch number is bigger than current
+	block := ledger.CurrentIndex()
 	storage.Put(ctx, snapshotEpoch, epochNum)
-	storage.Put(ctx, snapshotBlockKey, ledger.CurrentIndex())
+	storage.Put(ctx, snapshotBlockKey, block)
+	putEpochTiming(ctx, epochNum, block, runtime.GetTime())
 
-	id := storage.Get(ctx, snapshotCurrentIDKey).(int)
-	id = (id + 1) % getSnapshotCount(ctx)
+	applyScheduledConfig(ctx, epochNum)
+	expireCandidates(ctx, epochNum)
+
+	prevID := storage.Get(ctx, snapshotCurrentIDKey).(int)
+	id := (prevID + 1) % getSnapshotCount(ctx)
 	storage.Put(ctx, snapshotCurrentIDKey, id)
 
 	// put netmap into actual snapshot
-	common.SetSerialized(ctx, snapshotKeyPrefix+string([]byte{byte(id)}), dataOnlineState)
+	diff, hash := fillSnapshot(ctx, prevID, id, epochNum)
+	storage.Put(ctx, append([]byte(snapshotHashPrefix), common.EpochKey(epochNum)...), hash)
 
 	// make clean up routines in other contracts
 	cleanup(ctx, epochNum)
 
+	runtime.Notify("NetmapChanged", epochNum, diff.added, diff.removed)
+	runtime.Notify("NewEpochSnapshotHash", epochNum, hash)
 	runtime.Notify("NewEpoch", epochNum)
 }
 
@@ -428,7 +603,17 @@ func LastEpochBlock() int {
 func Netmap() []storageNode {
 	ctx := storage.GetReadOnlyContext()
 	id := storage.Get(ctx, snapshotCurrentIDKey).(int)
-	return getSnapshot(ctx, snapshotKeyPrefix+string([]byte{byte(id)}))
+	return getSnapshot(ctx, id)
+}
+
+// ListNetmap method returns an iterator over structures that contain a byte
+// array of a stable marshalled netmap.NodeInfo structure and the node state
+// (online: 1, maintenance: 3). These structures contain Storage nodes of the
+// current epoch. Use it instead of Netmap method for big network maps.
+func ListNetmap() iterator.Iterator {
+	ctx := storage.GetReadOnlyContext()
+	id := storage.Get(ctx, snapshotCurrentIDKey).(int)
+	return storage.Find(ctx, snapshotPrefix(id), storage.ValuesOnly|storage.DeserializeValues)
 }
 
 // NetmapCandidates method returns a list of structures that contain the node state
@@ -439,34 +624,107 @@ func NetmapCandidates() []netmapNode {
 	return getNetmapNodes(ctx)
 }
 
-// Snapshot method returns a list of structures that contain the node state
-// (online: 1) and a byte array of a stable marshalled netmap.NodeInfo structure.
+// Candidate method returns a structure that contains the node state and a byte
+// array of a stable marshalled netmap.NodeInfo structure of the Storage node
+// candidate with the specified public key. It returns Null if there is no such
+// candidate.
+func Candidate(publicKey interop.PublicKey) interface{} {
+	ctx := storage.GetReadOnlyContext()
+	data := storage.Get(ctx, append(candidatePrefix, publicKey...))
+	if data == nil {
+		return nil
+	}
+
+	return std.Deserialize(data.([]byte))
+}
+
+// ListCandidates method returns an iterator over the structures returned by
+// NetmapCandidates method. Use it instead of NetmapCandidates method for big
+// network maps.
+func ListCandidates() iterator.Iterator {
+	ctx := storage.GetReadOnlyContext()
+	return storage.Find(ctx, candidatePrefix, storage.ValuesOnly|storage.DeserializeValues)
+}
+
+// Snapshot method returns a list of structures that contain a byte array of a
+// stable marshalled netmap.NodeInfo structure.
 // These structures contain Storage nodes of the specified epoch.
 //
 // Netmap contract contains only two recent network map snapshots: current and
 // previous epoch. For diff bigger than 1 or less than 0, the method throws panic.
 func Snapshot(diff int) []storageNode {
 	ctx := storage.GetReadOnlyContext()
-	count := getSnapshotCount(ctx)
-	if diff < 0 || count <= diff {
-		panic("incorrect diff")
+	return getSnapshot(ctx, snapshotID(ctx, diff))
+}
+
+// ListSnapshot method returns an iterator over the structures with the node
+// state, see ListNetmap, of the snapshot returned by Snapshot method. Use it
+// instead of Snapshot method for big network maps.
+func ListSnapshot(diff int) iterator.Iterator {
+	ctx := storage.GetReadOnlyContext()
+	return storage.Find(ctx, snapshotPrefix(snapshotID(ctx, diff)), storage.ValuesOnly|storage.DeserializeValues)
+}
+
+// ListCandidatesByAttribute method returns an iterator over public keys of the
+// Storage node candidates for the next epoch which have an attribute with the
+// specified key and value, e.g. `Continent` and `Europe`.
+func ListCandidatesByAttribute(key, value string) iterator.Iterator {
+	ctx := storage.GetReadOnlyContext()
+	prefix := append([]byte(candidateAttrPrefix), attributeID(key, value)...)
+	return storage.Find(ctx, prefix, storage.ValuesOnly)
+}
+
+// ListNetmapByAttribute method returns an iterator over public keys of the
+// Storage nodes of the current epoch which have an attribute with the specified
+// key and value, e.g. `UN-LOCODE` and `RU MOW`.
+func ListNetmapByAttribute(key, value string) iterator.Iterator {
+	ctx := storage.GetReadOnlyContext()
+	prefix := append([]byte(netmapAttrPrefix), attributeID(key, value)...)
+	return storage.Find(ctx, prefix, storage.ValuesOnly)
+}
+
+// NodeStats method returns a structure that contains the first and the last
+// epochs when the Storage node with the specified public key was online in the
+// network map and the total number of such epochs. It returns Null if the node
+// has never been online in the network map.
+func NodeStats(publicKey interop.PublicKey) interface{} {
+	ctx := storage.GetReadOnlyContext()
+	data := storage.Get(ctx, append([]byte(nodeStatsPrefix), publicKey...))
+	if data == nil {
+		return nil
 	}
 
-	id := storage.Get(ctx, snapshotCurrentIDKey).(int)
-	needID := (id - diff + count) % count
-	key := snapshotKeyPrefix + string([]byte{byte(needID)})
-	return getSnapshot(ctx, key)
+	return std.Deserialize(data.([]byte))
+}
+
+// ListNodeStats method returns an iterator over structures that contain the
+// public key of the Storage node and the structure returned by NodeStats method.
+func ListNodeStats() iterator.Iterator {
+	ctx := storage.GetReadOnlyContext()
+	return storage.Find(ctx, []byte(nodeStatsPrefix), storage.RemovePrefix|storage.DeserializeValues)
 }
 
 func getSnapshotCount(ctx storage.Context) int {
 	return storage.Get(ctx, snapshotCountKey).(int)
 }
 
+// snapshotID returns the index of the snapshot made diff epochs ago.
+func snapshotID(ctx storage.Context, diff int) int {
+	count := getSnapshotCount(ctx)
+	if diff < 0 || count <= diff {
+		panic("incorrect diff")
+	}
+
+	id := storage.Get(ctx, snapshotCurrentIDKey).(int)
+	return (id - diff + count) % count
+}
+
 // UpdateSnapshotCount updates the number of the stored snapshots.
 // If a new number is less than the old one, old snapshots are removed.
 // Otherwise, history is extended with empty snapshots, so
 // `Snapshot` method can return invalid results for `diff = new-old` epochs
-// until `diff` epochs have passed.
+// until `diff` epochs have passed. Methods which take an epoch read such
+// snapshots from the archive if it is enabled.
 func UpdateSnapshotCount(count int) {
 	common.CheckAlphabetWitness(common.AlphabetAddress())
 	if count < 0 {
@@ -532,29 +790,78 @@ func UpdateSnapshotCount(count int) {
 		delStart, delFinish = count, curr
 	}
 	for k := delStart; k < delFinish; k++ {
-		key := snapshotKeyPrefix + string([]byte{byte(k)})
-		storage.Delete(ctx, key)
+		clearSnapshot(ctx, k)
 	}
 }
 
 func moveSnapshot(ctx storage.Context, from, to int) {
-	keyFrom := snapshotKeyPrefix + string([]byte{byte(from)})
-	keyTo := snapshotKeyPrefix + string([]byte{byte(to)})
-	data := storage.Get(ctx, keyFrom)
-	storage.Put(ctx, keyTo, data)
+	clearSnapshot(ctx, to)
+
+	prefixTo := snapshotPrefix(to)
+	it := storage.Find(ctx, snapshotPrefix(from), storage.RemovePrefix)
+	for iterator.Next(it) {
+		kv := iterator.Value(it).(struct {
+			key   []byte
+			value []byte
+		})
+		storage.Put(ctx, append(prefixTo, kv.key...), kv.value)
+	}
 }
 
-// SnapshotByEpoch method returns a list of structures that contain the node state
-// (online: 1) and a byte array of a stable marshalled netmap.NodeInfo structure.
+// SnapshotByEpoch method returns a list of structures that contain a byte array of a
+// stable marshalled netmap.NodeInfo structure.
 // These structures contain Storage nodes of the specified epoch.
 //
-// Netmap contract contains only two recent network map snapshot: current and
-// previous epoch. For all others epoch method throws panic.
+// Netmap contract contains network map snapshots of the last snapshot count
+// epochs, see UpdateSnapshotCount. Older snapshots are read from the archive
+// if it is enabled in the netmap config, see SnapshotArchiveRetentionKey.
+// For all others epoch method throws panic.
 func SnapshotByEpoch(epoch int) []storageNode {
 	ctx := storage.GetReadOnlyContext()
-	currentEpoch := storage.Get(ctx, snapshotEpoch).(int)
+	return getSnapshotByPrefix(ctx, snapshotPrefixByEpoch(ctx, epoch))
+}
+
+// ListSnapshotByEpoch method returns an iterator over the structures with
+// the node state, see ListNetmap, of the snapshot returned by SnapshotByEpoch
+// method. Use it instead of SnapshotByEpoch method for big network maps.
+func ListSnapshotByEpoch(epoch int) iterator.Iterator {
+	ctx := storage.GetReadOnlyContext()
+	return storage.Find(ctx, snapshotPrefixByEpoch(ctx, epoch), storage.ValuesOnly|storage.DeserializeValues)
+}
+
+// SnapshotNodeByEpoch method returns a structure that contains a byte array of
+// a stable marshalled netmap.NodeInfo structure and the state (online: 1,
+// maintenance: 3) of the Storage node with the specified public key in the
+// network map of the specified epoch.
+// It returns Null if the node is not in that network map. The network map must
+// be available, see SnapshotByEpoch.
+func SnapshotNodeByEpoch(epoch int, publicKey interop.PublicKey) interface{} {
+	ctx := storage.GetReadOnlyContext()
+	data := storage.Get(ctx, append(snapshotPrefixByEpoch(ctx, epoch), publicKey...))
+	if data == nil {
+		return nil
+	}
+
+	return std.Deserialize(data.([]byte))
+}
+
+// SnapshotHash method returns the hash of the network map of the specified
+// epoch. It is a SHA256 hash of the concatenated SHA256 hashes of the stable
+// marshalled netmap.NodeInfo structures of the network map sorted by public
+// key. Hashes are kept for all epochs since they are supported, for other
+// epochs the method returns Null.
+func SnapshotHash(epoch int) interface{} {
+	ctx := storage.GetReadOnlyContext()
+	return storage.Get(ctx, append([]byte(snapshotHashPrefix), common.EpochKey(epoch)...))
+}
 
-	return Snapshot(currentEpoch - epoch)
+// NetmapDiff method returns a structure that contains public keys of the storage
+// nodes which are present in the network map of toEpoch but not fromEpoch
+// (added) and vice versa (removed). Both network maps must be available, see
+// SnapshotByEpoch.
+func NetmapDiff(fromEpoch, toEpoch int) netmapDiff {
+	ctx := storage.GetReadOnlyContext()
+	return diffSnapshots(ctx, snapshotPrefixByEpoch(ctx, fromEpoch), snapshotPrefixByEpoch(ctx, toEpoch))
 }
 
 // Config returns configuration value of NeoFS configuration. If key does
@@ -566,6 +873,10 @@ func Config(key []byte) interface{} {
 
 // SetConfig key-value pair as a NeoFS runtime configuration value. It can be invoked
 // only by Alphabet nodes.
+//
+// Well-known values are checked against the schema returned by ConfigSchema
+// method. Other keys are accepted only if UnknownConfigAllowed config value is
+// set to true.
 func SetConfig(id, key, val []byte) {
 	ctx := storage.GetContext()
 	notaryDisabled := storage.Get(ctx, notaryDisabledKey).(bool)
@@ -586,6 +897,8 @@ func SetConfig(id, key, val []byte) {
 		common.CheckAlphabetWitness(multiaddr)
 	}
 
+	checkConfig(ctx, key, val)
+
 	if notaryDisabled {
 		threshold := len(alphabet)*2/3 + 1
 
@@ -628,7 +941,7 @@ func Version() int {
 	return common.Version
 }
 
-func addToNetmap(ctx storage.Context, n storageNode) {
+func addToNetmap(ctx storage.Context, n storageNode, attrs []nodeAttribute) {
 	var (
 		newNode    = n.info
 		newNodeKey = newNode[2:35]
@@ -640,28 +953,269 @@ func addToNetmap(ctx storage.Context, n storageNode) {
 		}
 	)
 
+	checkNotRotated(ctx, newNodeKey)
+
 	storage.Put(ctx, storageKey, std.Serialize(node))
+	indexCandidate(ctx, newNodeKey, attrs)
+
+	epoch := storage.Get(ctx, snapshotEpoch).(int)
+	storage.Put(ctx, append([]byte(bootstrapEpochPrefix), newNodeKey...), epoch)
 }
 
 func removeFromNetmap(ctx storage.Context, key interop.PublicKey) {
 	storageKey := append(candidatePrefix, key...)
 	storage.Delete(ctx, storageKey)
+	storage.Delete(ctx, append([]byte(bootstrapEpochPrefix), key...))
+	unindexCandidate(ctx, key)
+}
+
+// rotateNodeKey replaces the candidate with the old key by the node with
+// the new key.
+func rotateNodeKey(ctx storage.Context, oldKey interop.PublicKey, n storageNode, attrs []nodeAttribute) {
+	newKey := n.info[2:35]
+	if common.BytesEqual(oldKey, newKey) {
+		panic("same public key")
+	}
+
+	data := storage.Get(ctx, append(candidatePrefix, oldKey...))
+	if data == nil {
+		panic("peer is missing")
+	}
+	if storage.Get(ctx, append(candidatePrefix, newKey...)) != nil {
+		panic("peer already exists")
+	}
+	checkNotRotated(ctx, newKey)
+
+	state := std.Deserialize(data.([]byte)).(netmapNode).state
+
+	removeFromNetmap(ctx, oldKey)
+	addToNetmap(ctx, n, attrs)
+	if state != OnlineState {
+		storageKey := append(candidatePrefix, newKey...)
+		common.SetSerialized(ctx, storageKey, netmapNode{node: n, state: state})
+	}
+
+	oldStatsKey := append([]byte(nodeStatsPrefix), oldKey...)
+	stats := storage.Get(ctx, oldStatsKey)
+	if stats != nil {
+		storage.Put(ctx, append([]byte(nodeStatsPrefix), newKey...), stats)
+		storage.Delete(ctx, oldStatsKey)
+	}
+
+	storage.Put(ctx, append([]byte(nextNodeKeyPrefix), oldKey...), newKey)
+	storage.Put(ctx, append([]byte(previousNodeKeyPrefix), newKey...), oldKey)
+}
+
+// checkNotRotated panics if the public key has been replaced by RotateNodeKey
+// method.
+func checkNotRotated(ctx storage.Context, key interop.PublicKey) {
+	if storage.Get(ctx, append([]byte(nextNodeKeyPrefix), key...)) != nil {
+		panic("public key has already been rotated")
+	}
+}
+
+// attributeID returns an identifier of the node attribute in the attribute index.
+func attributeID(key, value string) []byte {
+	return crypto.Ripemd160([]byte(key + "\x00" + value))
+}
+
+// indexCandidate replaces indexed attributes of the candidate with the specified ones.
+func indexCandidate(ctx storage.Context, key interop.PublicKey, attrs []nodeAttribute) {
+	unindexCandidate(ctx, key)
+
+	ids := [][]byte{}
+	for i := range attrs {
+		id := attributeID(attrs[i].key, attrs[i].value)
+		indexKey := append([]byte(candidateAttrPrefix), id...)
+		storage.Put(ctx, append(indexKey, key...), key)
+		ids = append(ids, id)
+	}
+
+	common.SetSerialized(ctx, append([]byte(nodeAttrPrefix), key...), ids)
+}
+
+// unindexCandidate removes all indexed attributes of the candidate.
+func unindexCandidate(ctx storage.Context, key interop.PublicKey) {
+	ids := getAttributeIDs(ctx, key)
+	for i := range ids {
+		indexKey := append([]byte(candidateAttrPrefix), ids[i]...)
+		storage.Delete(ctx, append(indexKey, key...))
+	}
+
+	storage.Delete(ctx, append([]byte(nodeAttrPrefix), key...))
+}
+
+// getAttributeIDs returns identifiers of the indexed attributes of the candidate.
+func getAttributeIDs(ctx storage.Context, key interop.PublicKey) [][]byte {
+	data := storage.Get(ctx, append([]byte(nodeAttrPrefix), key...))
+	if data == nil {
+		return [][]byte{}
+	}
+
+	return std.Deserialize(data.([]byte)).([][]byte)
+}
+
+// expireCandidates removes online candidates which have not been added again
+// during the number of epochs set in the netmap config. Maintenance candidates
+// do not expire.
+func expireCandidates(ctx storage.Context, epoch int) {
+	ttl := getConfigInt(ctx, CandidateExpirationKey)
+	if ttl <= 0 {
+		return
+	}
+
+	it := storage.Find(ctx, candidatePrefix, storage.RemovePrefix|storage.DeserializeValues)
+	for iterator.Next(it) {
+		kv := iterator.Value(it).(struct {
+			key  []byte
+			node netmapNode
+		})
+		if kv.node.state != OnlineState {
+			continue
+		}
+
+		epochKey := append([]byte(bootstrapEpochPrefix), kv.key...)
+		lastEpoch := storage.Get(ctx, epochKey)
+		if lastEpoch == nil {
+			// candidate has been added before the expiration was supported
+			storage.Put(ctx, epochKey, epoch)
+			continue
+		}
+
+		if epoch-lastEpoch.(int) > ttl {
+			removeFromNetmap(ctx, kv.key)
+			runtime.Notify("CandidateExpired", interop.PublicKey(kv.key))
+		}
+	}
+}
+
+func updateCandidateState(ctx storage.Context, key interop.PublicKey, state nodeState) {
+	switch state {
+	case OfflineState:
+		removeFromNetmap(ctx, key)
+		runtime.Log("remove storage node from the network map")
+	case MaintenanceState:
+		storageKey := append(candidatePrefix, key...)
+		data := storage.Get(ctx, storageKey)
+		if data == nil {
+			panic("peer is missing")
+		}
+
+		node := std.Deserialize(data.([]byte)).(netmapNode)
+		node.state = state
+		storage.Put(ctx, storageKey, std.Serialize(node))
+		runtime.Log("storage node goes into maintenance")
+	default:
+		panic("unsupported state")
+	}
 }
 
-func filterNetmap(ctx storage.Context, st nodeState) []storageNode {
+// fillSnapshot replaces snapshot with the specified index with candidates
+// which should get into the next network map, i.e. online and maintenance ones.
+// Candidates are iterated once: the snapshot is archived, the attribute index of
+// the current network map is rebuilt and participation statistics are updated
+// on the way. It returns the difference with the snapshot of the previous epoch
+// and the hash of the new snapshot, see SnapshotHash.
+func fillSnapshot(ctx storage.Context, prevID, id, epoch int) (netmapDiff, interop.Hash256) {
 	var (
-		netmap = getNetmapNodes(ctx)
-		result = []storageNode{}
+		prevPrefix = snapshotPrefix(prevID)
+		prefix     = snapshotPrefix(id)
+		diff       = netmapDiff{
+			added:   []interop.PublicKey{},
+			removed: []interop.PublicKey{},
+		}
 	)
 
-	for i := 0; i < len(netmap); i++ {
-		item := netmap[i]
-		if item.state == st {
-			result = append(result, item.node)
+	it := storage.Find(ctx, prevPrefix, storage.KeysOnly|storage.RemovePrefix)
+	for iterator.Next(it) {
+		key := iterator.Value(it).([]byte)
+		if storage.Get(ctx, append(candidatePrefix, key...)) == nil {
+			diff.removed = append(diff.removed, key)
 		}
 	}
 
-	return result
+	// Nodes of the replaced snapshot which are still candidates are
+	// overwritten below, so only the other ones are removed. It also works
+	// when the replaced snapshot is the previous one, i.e. a single snapshot
+	// is kept.
+	it = storage.Find(ctx, prefix, storage.KeysOnly|storage.RemovePrefix)
+	for iterator.Next(it) {
+		key := iterator.Value(it).([]byte)
+		if storage.Get(ctx, append(candidatePrefix, key...)) == nil {
+			storage.Delete(ctx, append(prefix, key...))
+		}
+	}
+
+	clearPrefix(ctx, []byte(netmapAttrPrefix))
+
+	retention := getConfigInt(ctx, SnapshotArchiveRetentionKey)
+	pruneArchive(ctx, epoch, retention)
+
+	var (
+		archive = archivePrefix(epoch)
+		count   = 0
+		hashes  = []byte{}
+	)
+
+	it = storage.Find(ctx, candidatePrefix, storage.RemovePrefix|storage.DeserializeValues)
+	for iterator.Next(it) {
+		kv := iterator.Value(it).(struct {
+			key  []byte
+			node netmapNode
+		})
+		if kv.node.state != OnlineState && kv.node.state != MaintenanceState {
+			continue
+		}
+
+		if storage.Get(ctx, append(prevPrefix, kv.key...)) == nil {
+			diff.added = append(diff.added, kv.key)
+		}
+
+		data := std.Serialize(snapshotNode{
+			info:  kv.node.node.info,
+			state: kv.node.state,
+		})
+		storage.Put(ctx, append(prefix, kv.key...), data)
+		if retention > 0 {
+			storage.Put(ctx, append(archive, kv.key...), data)
+			count++
+		}
+
+		ids := getAttributeIDs(ctx, kv.key)
+		for i := range ids {
+			indexKey := append([]byte(netmapAttrPrefix), ids[i]...)
+			storage.Put(ctx, append(indexKey, kv.key...), kv.key)
+		}
+
+		if kv.node.state == OnlineState {
+			updateNodeStats(ctx, kv.key, epoch)
+		}
+
+		hashes = append(hashes, crypto.Sha256(kv.node.node.info)...)
+	}
+
+	if retention > 0 {
+		storage.Put(ctx, append([]byte(archiveEpochPrefix), common.EpochKey(epoch)...), count)
+	}
+
+	return diff, crypto.Sha256(hashes)
+}
+
+// updateNodeStats updates participation statistics of the online node
+// in the network map of the specified epoch.
+func updateNodeStats(ctx storage.Context, key interop.PublicKey, epoch int) {
+	statsKey := append([]byte(nodeStatsPrefix), key...)
+
+	stats := nodeStats{firstEpoch: epoch}
+	data := storage.Get(ctx, statsKey)
+	if data != nil {
+		stats = std.Deserialize(data.([]byte)).(nodeStats)
+	}
+
+	stats.lastEpoch = epoch
+	stats.count++
+
+	common.SetSerialized(ctx, statsKey, stats)
 }
 
 func getNetmapNodes(ctx storage.Context) []netmapNode {
@@ -676,13 +1230,160 @@ func getNetmapNodes(ctx storage.Context) []netmapNode {
 	return result
 }
 
-func getSnapshot(ctx storage.Context, key string) []storageNode {
-	data := storage.Get(ctx, key)
-	if data != nil {
-		return std.Deserialize(data.([]byte)).([]storageNode)
+// snapshotPrefix returns storage prefix of the snapshot with the specified index.
+// Each snapshot node is stored separately with the public key as a key postfix.
+func snapshotPrefix(id int) []byte {
+	return []byte(snapshotKeyPrefix + string([]byte{byte(id)}))
+}
+
+// snapshotPrefixByEpoch returns storage prefix of the snapshot of the specified
+// epoch. Archived snapshots are looked up in the archive first, because the
+// snapshot ring can contain empty snapshots after the snapshot count has been
+// increased.
+func snapshotPrefixByEpoch(ctx storage.Context, epoch int) []byte {
+	if isArchived(ctx, epoch) {
+		return archivePrefix(epoch)
 	}
 
-	return []storageNode{}
+	currentEpoch := storage.Get(ctx, snapshotEpoch).(int)
+	return snapshotPrefix(snapshotID(ctx, currentEpoch-epoch))
+}
+
+// archivePrefix returns storage prefix of the archived snapshot of the specified epoch.
+func archivePrefix(epoch int) []byte {
+	return append([]byte(archiveNodePrefix), common.EpochKey(epoch)...)
+}
+
+func isArchived(ctx storage.Context, epoch int) bool {
+	key := append([]byte(archiveEpochPrefix), common.EpochKey(epoch)...)
+	return storage.Get(ctx, key) != nil
+}
+
+// pruneArchive removes archived snapshots which are older than the archive
+// retention.
+func pruneArchive(ctx storage.Context, epoch, retention int) {
+	it := storage.Find(ctx, []byte(archiveEpochPrefix), storage.KeysOnly|storage.RemovePrefix)
+	for iterator.Next(it) {
+		rawEpoch := iterator.Value(it).([]byte)
+		if common.EpochFromKey(rawEpoch) > epoch-retention {
+			break
+		}
+
+		storage.Delete(ctx, append([]byte(archiveEpochPrefix), rawEpoch...))
+		clearPrefix(ctx, append([]byte(archiveNodePrefix), rawEpoch...))
+	}
+}
+
+// diffSnapshots compares node sets stored with the public key postfix under
+// the specified prefixes.
+func diffSnapshots(ctx storage.Context, from, to []byte) netmapDiff {
+	diff := netmapDiff{
+		added:   []interop.PublicKey{},
+		removed: []interop.PublicKey{},
+	}
+
+	it := storage.Find(ctx, to, storage.KeysOnly|storage.RemovePrefix)
+	for iterator.Next(it) {
+		key := iterator.Value(it).([]byte)
+		if storage.Get(ctx, append(from, key...)) == nil {
+			diff.added = append(diff.added, key)
+		}
+	}
+
+	it = storage.Find(ctx, from, storage.KeysOnly|storage.RemovePrefix)
+	for iterator.Next(it) {
+		key := iterator.Value(it).([]byte)
+		if storage.Get(ctx, append(to, key...)) == nil {
+			diff.removed = append(diff.removed, key)
+		}
+	}
+
+	return diff
+}
+
+func getSnapshot(ctx storage.Context, id int) []storageNode {
+	return getSnapshotByPrefix(ctx, snapshotPrefix(id))
+}
+
+func getSnapshotByPrefix(ctx storage.Context, prefix []byte) []storageNode {
+	result := []storageNode{}
+
+	it := storage.Find(ctx, prefix, storage.ValuesOnly|storage.DeserializeValues)
+	for iterator.Next(it) {
+		node := iterator.Value(it).(snapshotNode)
+		result = append(result, storageNode{info: node.info})
+	}
+
+	return result
+}
+
+func clearSnapshot(ctx storage.Context, id int) {
+	clearPrefix(ctx, snapshotPrefix(id))
+}
+
+func clearPrefix(ctx storage.Context, prefix []byte) {
+	it := storage.Find(ctx, prefix, storage.KeysOnly)
+	for iterator.Next(it) {
+		storage.Delete(ctx, iterator.Value(it).([]byte))
+	}
+}
+
+// migrateCandidates rewrites candidates which have been stored with extra
+// fields in the NodeInfo structure, so all candidates have the same format.
+// Attributes of the candidates stored before the attribute index was supported
+// are indexed, candidates with malformed NodeInfo are left unindexed, so they
+// don't block the update. The network map index is rebuilt by NewEpoch method.
+func migrateCandidates(ctx storage.Context) {
+	indexed := storage.Get(ctx, attributeIndexKey) != nil
+
+	it := storage.Find(ctx, candidatePrefix, storage.None)
+	for iterator.Next(it) {
+		kv := iterator.Value(it).(struct {
+			key   []byte
+			value []byte
+		})
+
+		node := std.Deserialize(kv.value).(netmapNode)
+		normalized := std.Serialize(netmapNode{
+			node:  storageNode{info: node.node.info},
+			state: node.state,
+		})
+		if !common.BytesEqual(normalized, kv.value) {
+			storage.Put(ctx, kv.key, normalized)
+		}
+
+		if !indexed {
+			ni, err := decodeNodeInfo(node.node.info)
+			if err == "" {
+				indexCandidate(ctx, kv.key[len(candidatePrefix):], ni.attributes)
+			}
+		}
+	}
+
+	storage.Put(ctx, attributeIndexKey, true)
+}
+
+// migrateSnapshots moves network map snapshots stored as a single serialized
+// list into separate storage items for each node.
+func migrateSnapshots(ctx storage.Context) {
+	count := getSnapshotCount(ctx)
+	for id := 0; id < count; id++ {
+		prefix := snapshotPrefix(id)
+		data := storage.Get(ctx, prefix)
+		if data == nil {
+			continue
+		}
+		storage.Delete(ctx, prefix)
+
+		nodes := std.Deserialize(data.([]byte)).([]storageNode)
+		for i := range nodes {
+			info := nodes[i].info
+			common.SetSerialized(ctx, append(prefix, info[2:35]...), snapshotNode{
+				info:  info,
+				state: OnlineState,
+			})
+		}
+	}
 }
 
 func getConfig(ctx storage.Context, key interface{}) interface{} {
@@ -692,11 +1393,36 @@ func getConfig(ctx storage.Context, key interface{}) interface{} {
 	return storage.Get(ctx, storageKey)
 }
 
+func getConfigInt(ctx storage.Context, key string) int {
+	val := getConfig(ctx, []byte(key))
+	if val == nil {
+		return 0
+	}
+
+	return val.(int)
+}
+
+func getConfigBool(ctx storage.Context, key string) bool {
+	val := getConfig(ctx, []byte(key))
+	if val == nil {
+		return false
+	}
+
+	return val.(bool)
+}
+
 func setConfig(ctx storage.Context, key, val interface{}) {
 	postfix := key.([]byte)
 	storageKey := append(configPrefix, postfix...)
 
 	storage.Put(ctx, storageKey, val)
+
+	// epoch is not set yet on the initial deployment
+	epoch := storage.Get(ctx, snapshotEpoch)
+	if epoch == nil {
+		epoch = 0
+	}
+	putConfigHistory(ctx, postfix, val, epoch.(int))
 }
 
 func cleanup(ctx storage.Context, epoch int) {
@@ -0,0 +1,156 @@
+package netmap
+
+import (
+	"github.com/nspcc-dev/neo-go/pkg/interop"
+	"github.com/nspcc-dev/neofs-contract/common"
+)
+
+type (
+	// nodeInfo contains netmap.NodeInfo fields used by the contract.
+	nodeInfo struct {
+		publicKey  interop.PublicKey
+		addresses  []string
+		attributes []nodeAttribute
+	}
+
+	nodeAttribute struct {
+		key   string
+		value string
+	}
+)
+
+const (
+	// ErrInvalidNodeInfo is thrown when node info is not a valid stable
+	// marshaled netmap.NodeInfo structure.
+	ErrInvalidNodeInfo = "invalid node info format"
+	// ErrInvalidNodeKey is thrown when node info does not start with a
+	// compressed public key of the node.
+	ErrInvalidNodeKey = "invalid node public key"
+	// ErrMissingNodeAddress is thrown when node info does not contain any
+	// network address or contains an empty one.
+	ErrMissingNodeAddress = "missing node address"
+	// ErrInvalidNodeAttribute is thrown when node attribute does not
+	// contain both key and value.
+	ErrInvalidNodeAttribute = "invalid node attribute"
+)
+
+// V2 format
+const (
+	nodeInfoPublicKeyField  = 1
+	nodeInfoAddressesField  = 2
+	nodeInfoAttributesField = 3
+	nodeInfoStateField      = 4
+
+	attributeKeyField     = 1
+	attributeValueField   = 2
+	attributeParentsField = 3
+)
+
+// parseNodeInfo parses a stable marshaled netmap.NodeInfo structure. It panics
+// if the structure is malformed. Public key is always the first field, so
+// other contracts can take it from the fixed offset.
+func parseNodeInfo(data []byte) nodeInfo {
+	info, err := decodeNodeInfo(data)
+	if err != "" {
+		panic(err)
+	}
+
+	return info
+}
+
+// decodeNodeInfo parses a stable marshaled netmap.NodeInfo structure the same
+// way as parseNodeInfo does, but returns the error instead of panicking. The
+// error is empty if the structure is valid.
+func decodeNodeInfo(data []byte) (nodeInfo, string) {
+	info := nodeInfo{
+		addresses:  []string{},
+		attributes: []nodeAttribute{},
+	}
+
+	num, typ, start, end := common.ReadProtoField(data, 0)
+	if num != nodeInfoPublicKeyField || typ != common.ProtoLengthDelimited ||
+		start != 2 || end != 2+interop.PublicKeyCompressedLen ||
+		(data[2] != 0x02 && data[2] != 0x03) {
+		return info, ErrInvalidNodeKey
+	}
+	info.publicKey = data[start:end]
+
+	last := num
+	for offset := end; offset < len(data); offset = end {
+		num, typ, start, end = common.ReadProtoField(data, offset)
+		if end < 0 || num < last {
+			return info, ErrInvalidNodeInfo
+		}
+		last = num
+
+		switch num {
+		case nodeInfoAddressesField:
+			if typ != common.ProtoLengthDelimited {
+				return info, ErrInvalidNodeInfo
+			}
+			if start == end {
+				return info, ErrMissingNodeAddress
+			}
+			info.addresses = append(info.addresses, string(data[start:end]))
+		case nodeInfoAttributesField:
+			if typ != common.ProtoLengthDelimited {
+				return info, ErrInvalidNodeInfo
+			}
+			attr, err := decodeNodeAttribute(data[start:end])
+			if err != "" {
+				return info, err
+			}
+			info.attributes = append(info.attributes, attr)
+		case nodeInfoStateField:
+			if typ != common.ProtoVarint {
+				return info, ErrInvalidNodeInfo
+			}
+		default:
+			return info, ErrInvalidNodeInfo
+		}
+	}
+
+	if len(info.addresses) == 0 {
+		return info, ErrMissingNodeAddress
+	}
+
+	return info, ""
+}
+
+// decodeNodeAttribute parses a stable marshaled netmap.NodeInfo.Attribute
+// structure. It returns ErrInvalidNodeAttribute if the structure is malformed
+// or key or value is empty.
+func decodeNodeAttribute(data []byte) (nodeAttribute, string) {
+	var (
+		attr  nodeAttribute
+		last  int
+		start int
+		end   int
+	)
+
+	for offset := 0; offset < len(data); offset = end {
+		var num, typ int
+
+		num, typ, start, end = common.ReadProtoField(data, offset)
+		if end < 0 || num < last || typ != common.ProtoLengthDelimited {
+			return attr, ErrInvalidNodeAttribute
+		}
+		last = num
+
+		switch num {
+		case attributeKeyField:
+			attr.key = string(data[start:end])
+		case attributeValueField:
+			attr.value = string(data[start:end])
+		case attributeParentsField:
+		default:
+			return attr, ErrInvalidNodeAttribute
+		}
+	}
+
+	if len(attr.key) == 0 || len(attr.value) == 0 {
+		return attr, ErrInvalidNodeAttribute
+	}
+
+	return attr, ""
+}
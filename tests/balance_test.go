@@ -12,10 +12,14 @@ import (
 const balancePath = "../balance"
 
 func deployBalanceContract(t *testing.T, e *neotest.Executor, addrNetmap, addrContainer util.Uint160) util.Uint160 {
+	return deployBalanceContractNotary(t, e, false, addrNetmap, addrContainer)
+}
+
+func deployBalanceContractNotary(t *testing.T, e *neotest.Executor, notaryDisabled bool, addrNetmap, addrContainer util.Uint160) util.Uint160 {
 	c := neotest.CompileFile(t, e.CommitteeHash, balancePath, path.Join(balancePath, "config.yml"))
 
 	args := make([]interface{}, 3)
-	args[0] = false
+	args[0] = notaryDisabled
 	args[1] = addrNetmap
 	args[2] = addrContainer
 
@@ -3,10 +3,12 @@ package tests
 import (
 	"bytes"
 	"crypto/sha256"
+	"math/big"
 	"path"
 	"testing"
 
 	"github.com/mr-tron/base58"
+	"github.com/nspcc-dev/neo-go/pkg/core/state"
 	"github.com/nspcc-dev/neo-go/pkg/encoding/address"
 	"github.com/nspcc-dev/neo-go/pkg/neotest"
 	"github.com/nspcc-dev/neo-go/pkg/util"
@@ -25,8 +27,13 @@ const (
 )
 
 func deployContainerContract(t *testing.T, e *neotest.Executor, addrNetmap, addrBalance, addrNNS util.Uint160) util.Uint160 {
+	return deployContainerContractNotary(t, e, false, addrNetmap, addrBalance, addrNNS)
+}
+
+func deployContainerContractNotary(t *testing.T, e *neotest.Executor, notaryDisabled bool,
+	addrNetmap, addrBalance, addrNNS util.Uint160) util.Uint160 {
 	args := make([]interface{}, 6)
-	args[0] = int64(0)
+	args[0] = notaryDisabled
 	args[1] = addrNetmap
 	args[2] = addrBalance
 	args[3] = util.Uint160{} // not needed for now
@@ -55,9 +62,23 @@ func newContainerInvoker(t *testing.T) (*neotest.ContractInvoker, *neotest.Contr
 	return e.CommitteeInvoker(ctrContainer.Hash), e.CommitteeInvoker(ctrBalance.Hash), e.CommitteeInvoker(ctrNetmap.Hash)
 }
 
-func setContainerOwner(c []byte, acc neotest.Signer) {
-	owner, _ := base58.Decode(address.Uint160ToString(acc.ScriptHash()))
-	copy(c[6:], owner)
+// newNotaryDisabledContainerInvoker returns container and balance contracts
+// deployed in notary disabled environment with several alphabet nodes.
+func newNotaryDisabledContainerInvoker(t *testing.T) (*neotest.ContractInvoker, *neotest.ContractInvoker, []neotest.Signer) {
+	e := newMultiExecutor(t)
+
+	ctrNNS := neotest.CompileFile(t, e.CommitteeHash, nnsPath, path.Join(nnsPath, "config.yml"))
+	ctrNetmap := neotest.CompileFile(t, e.CommitteeHash, netmapPath, path.Join(netmapPath, "config.yml"))
+	ctrBalance := neotest.CompileFile(t, e.CommitteeHash, balancePath, path.Join(balancePath, "config.yml"))
+	ctrContainer := neotest.CompileFile(t, e.CommitteeHash, containerPath, path.Join(containerPath, "config.yml"))
+
+	e.DeployContract(t, ctrNNS, nil)
+	deployNetmapContract(t, e, ctrBalance.Hash, ctrContainer.Hash,
+		container.RegistrationFeeKey, int64(containerFee),
+		container.AliasFeeKey, int64(containerAliasFee))
+	deployBalanceContractNotary(t, e, true, ctrNetmap.Hash, ctrContainer.Hash)
+	deployContainerContractNotary(t, e, true, ctrNetmap.Hash, ctrBalance.Hash, ctrNNS.Hash)
+	return e.CommitteeInvoker(ctrContainer.Hash), e.CommitteeInvoker(ctrBalance.Hash), alphabetSigners(t, e)
 }
 
 type testContainer struct {
@@ -65,10 +86,19 @@ type testContainer struct {
 	value, sig, pub, token []byte
 }
 
-func dummyContainer(owner neotest.Signer) testContainer {
-	value := randomBytes(100)
-	value[1] = 0 // zero offset
-	setContainerOwner(value, owner)
+// dummyContainer returns a stable marshaled container of the owner with
+// the specified attributes given as key-value pairs.
+func dummyContainer(owner neotest.Signer, attrs ...string) testContainer {
+	ownerID, _ := base58.Decode(address.Uint160ToString(owner.ScriptHash()))
+
+	value := appendProtoBytes(nil, 1, nil) // zero offset
+	value = appendProtoBytes(value, 2, appendProtoBytes(nil, 1, ownerID))
+	value = appendProtoBytes(value, 3, randomBytes(16))
+	for i := 0; i < len(attrs); i += 2 {
+		attr := appendProtoBytes(nil, 1, []byte(attrs[i]))
+		attr = appendProtoBytes(attr, 2, []byte(attrs[i+1]))
+		value = appendProtoBytes(value, 5, attr)
+	}
 
 	return testContainer{
 		id:    sha256.Sum256(value),
@@ -94,22 +124,92 @@ func TestContainerCount(t *testing.T) {
 	acc1, cnt1 := addContainer(t, c, cBal)
 	checkCount(t, 1)
 
-	_, cnt2 := addContainer(t, c, cBal)
+	acc2, cnt2 := addContainer(t, c, cBal)
 	checkCount(t, 2)
 
 	// Same owner.
 	cnt3 := dummyContainer(acc1)
 	balanceMint(t, cBal, acc1, containerFee*1, []byte{})
 	c.Invoke(t, stackitem.Null{}, "put", cnt3.value, cnt3.sig, cnt3.pub, cnt3.token)
+	checkCount(t, 3)
+	checkContainersOf(t, c, nil, cnt1, cnt2, cnt3)
+	checkContainersOf(t, c, acc1, cnt1, cnt3)
+	checkContainersOf(t, c, acc2, cnt2)
 
 	c.Invoke(t, stackitem.Null{}, "delete", cnt1.id[:], cnt1.sig, cnt1.token)
 	checkCount(t, 2)
+	checkContainersOf(t, c, nil, cnt2, cnt3)
+	checkContainersOf(t, c, acc1, cnt3)
 
 	c.Invoke(t, stackitem.Null{}, "delete", cnt2.id[:], cnt2.sig, cnt2.token)
 	checkCount(t, 1)
+	checkContainersOf(t, c, acc2)
 
 	c.Invoke(t, stackitem.Null{}, "delete", cnt3.id[:], cnt3.sig, cnt3.token)
 	checkCount(t, 0)
+	checkContainersOf(t, c, nil)
+	checkContainersOf(t, c, acc1)
+}
+
+func checkContainersOf(t *testing.T, c *neotest.ContractInvoker, acc neotest.Signer, cnts ...testContainer) {
+	var owner []byte
+	if acc != nil {
+		owner, _ = base58.Decode(address.Uint160ToString(acc.ScriptHash()))
+		c.Invoke(t, len(cnts), "countOf", owner)
+	}
+
+	s, err := c.TestInvoke(t, "containersOf", owner)
+	require.NoError(t, err)
+	requireIteratorBytes(t, s, containerIDs(cnts))
+}
+
+func TestContainerAttributes(t *testing.T) {
+	c, cBal, _ := newContainerInvoker(t)
+
+	acc := c.NewAccount(t)
+	cnt1 := dummyContainer(acc, "Name", "first", "Tag", "common")
+	cnt2 := dummyContainer(acc, "Name", "second", "Tag", "common")
+	cnt3 := dummyContainer(acc)
+
+	balanceMint(t, cBal, acc, containerFee*3, []byte{})
+	for _, cnt := range []testContainer{cnt1, cnt2, cnt3} {
+		c.Invoke(t, stackitem.Null{}, "put", cnt.value, cnt.sig, cnt.pub, cnt.token)
+	}
+
+	t.Run("missing container", func(t *testing.T) {
+		id := cnt1.id
+		id[0] ^= 0xFF
+		c.InvokeFail(t, container.NotFoundError, "attributes", id[:])
+	})
+
+	c.Invoke(t, stackitem.NewMapWithValue([]stackitem.MapElement{
+		{Key: stackitem.Make("Name"), Value: stackitem.Make("first")},
+		{Key: stackitem.Make("Tag"), Value: stackitem.Make("common")},
+	}), "attributes", cnt1.id[:])
+	c.Invoke(t, stackitem.NewMap(), "attributes", cnt3.id[:])
+
+	checkContainersByAttribute(t, c, "Name", "first", cnt1)
+	checkContainersByAttribute(t, c, "Name", "second", cnt2)
+	checkContainersByAttribute(t, c, "Tag", "common", cnt1, cnt2)
+	checkContainersByAttribute(t, c, "Tag", "unknown")
+
+	c.Invoke(t, stackitem.Null{}, "delete", cnt1.id[:], cnt1.sig, cnt1.token)
+	checkContainersByAttribute(t, c, "Name", "first")
+	checkContainersByAttribute(t, c, "Tag", "common", cnt2)
+}
+
+func checkContainersByAttribute(t *testing.T, c *neotest.ContractInvoker, key, value string, cnts ...testContainer) {
+	s, err := c.TestInvoke(t, "containersByAttribute", key, value)
+	require.NoError(t, err)
+	requireIteratorBytes(t, s, containerIDs(cnts))
+}
+
+func containerIDs(cnts []testContainer) [][]byte {
+	ids := make([][]byte, len(cnts))
+	for i := range cnts {
+		ids[i] = cnts[i].id[:]
+	}
+	return ids
 }
 
 func TestContainerPut(t *testing.T) {
@@ -128,10 +228,19 @@ func TestContainerPut(t *testing.T) {
 
 	c.Invoke(t, stackitem.Null{}, "put", putArgs...)
 
+	t.Run("container already exists", func(t *testing.T) {
+		balanceMint(t, cBal, acc, containerFee*1, []byte{})
+		c.InvokeFail(t, container.AlreadyExistsError, "put", putArgs...)
+
+		putArgs := []interface{}{cnt.value, cnt.sig, cnt.pub, cnt.token, "mycnt", ""}
+		c.InvokeFail(t, container.AlreadyExistsError, "putNamed", putArgs...)
+	})
+
 	t.Run("with nice names", func(t *testing.T) {
 		ctrNNS := neotest.CompileFile(t, c.CommitteeHash, nnsPath, path.Join(nnsPath, "config.yml"))
 		nnsHash := ctrNNS.Hash
 
+		cnt := dummyContainer(acc)
 		balanceMint(t, cBal, acc, containerFee*1, []byte{})
 
 		putArgs := []interface{}{cnt.value, cnt.sig, cnt.pub, cnt.token, "mycnt", ""}
@@ -149,6 +258,8 @@ func TestContainerPut(t *testing.T) {
 		cNNS.Invoke(t, expected, "resolve", "mycnt.neofs", int64(nns.TXT))
 
 		t.Run("name is already taken", func(t *testing.T) {
+			cnt := dummyContainer(acc)
+			putArgs := []interface{}{cnt.value, cnt.sig, cnt.pub, cnt.token, "mycnt", ""}
 			c.InvokeFail(t, "name is already taken", "putNamed", putArgs...)
 		})
 
@@ -180,6 +291,75 @@ func TestContainerPut(t *testing.T) {
 	})
 }
 
+func TestContainerAlias(t *testing.T) {
+	c, cBal, _ := newContainerInvoker(t)
+
+	ctrNNS := neotest.CompileFile(t, c.CommitteeHash, nnsPath, path.Join(nnsPath, "config.yml"))
+	cNNS := c.CommitteeInvoker(ctrNNS.Hash)
+
+	acc, cnt := addContainer(t, c, cBal)
+	sig, pub, token := randomBytes(64), randomBytes(33), randomBytes(42)
+	expected := stackitem.NewArray([]stackitem.Item{
+		stackitem.NewByteArray([]byte(base58.Encode(cnt.id[:]))),
+	})
+
+	t.Run("missing container", func(t *testing.T) {
+		id := cnt.id
+		id[0] ^= 0xFF
+		c.InvokeFail(t, container.NotFoundError, "setAlias", id[:], "mycnt", "", sig, pub, token)
+		c.InvokeFail(t, container.NotFoundError, "deleteAlias", id[:], sig, token)
+	})
+
+	c.InvokeFail(t, "insufficient balance to set alias", "setAlias", cnt.id[:], "mycnt", "", sig, pub, token)
+
+	balanceMint(t, cBal, acc, containerAliasFee*2, []byte{})
+	c.WithSigners(acc).InvokeFail(t, common.ErrAlphabetWitnessFailed, "setAlias",
+		cnt.id[:], "mycnt", "", sig, pub, token)
+
+	h := c.Invoke(t, stackitem.Null{}, "setAlias", cnt.id[:], "mycnt", "", sig, pub, token)
+	aer := c.CheckHalt(t, h)
+	ev := aer.Events[len(aer.Events)-1]
+	require.Equal(t, "SetAliasSuccess", ev.Name)
+	require.Equal(t, [][]byte{cnt.id[:], []byte("mycnt.neofs")},
+		stackItemsToBytes(t, ev.Item.Value().([]stackitem.Item)))
+	cNNS.Invoke(t, expected, "resolve", "mycnt.neofs", int64(nns.TXT))
+
+	t.Run("same name", func(t *testing.T) {
+		h := c.Invoke(t, stackitem.Null{}, "setAlias", cnt.id[:], "mycnt", "", sig, pub, token)
+		require.Equal(t, 0, len(c.CheckHalt(t, h).Events))
+		cNNS.Invoke(t, expected, "resolve", "mycnt.neofs", int64(nns.TXT))
+	})
+
+	t.Run("name is already taken", func(t *testing.T) {
+		acc2, cnt2 := addContainer(t, c, cBal)
+		balanceMint(t, cBal, acc2, containerAliasFee*1, []byte{})
+		c.InvokeFail(t, "name is already taken", "setAlias", cnt2.id[:], "mycnt", "", sig, pub, token)
+	})
+
+	// The previous alias is replaced.
+	c.Invoke(t, stackitem.Null{}, "setAlias", cnt.id[:], "other", "", sig, pub, token)
+	cNNS.Invoke(t, stackitem.Null{}, "resolve", "mycnt.neofs", int64(nns.TXT))
+	cNNS.Invoke(t, expected, "resolve", "other.neofs", int64(nns.TXT))
+
+	c.InvokeFail(t, "insufficient balance to set alias", "setAlias", cnt.id[:], "mycnt", "", sig, pub, token)
+
+	c.WithSigners(acc).InvokeFail(t, common.ErrAlphabetWitnessFailed, "deleteAlias",
+		cnt.id[:], sig, token)
+
+	h = c.Invoke(t, stackitem.Null{}, "deleteAlias", cnt.id[:], sig, token)
+	aer = c.CheckHalt(t, h)
+	ev = aer.Events[len(aer.Events)-1]
+	require.Equal(t, "DeleteAliasSuccess", ev.Name)
+	require.Equal(t, [][]byte{cnt.id[:], []byte("other.neofs")},
+		stackItemsToBytes(t, ev.Item.Value().([]stackitem.Item)))
+	cNNS.Invoke(t, stackitem.Null{}, "resolve", "other.neofs", int64(nns.TXT))
+
+	t.Run("missing alias", func(t *testing.T) {
+		h := c.Invoke(t, stackitem.Null{}, "deleteAlias", cnt.id[:], sig, token)
+		require.Equal(t, 0, len(c.CheckHalt(t, h).Events))
+	})
+}
+
 func addContainer(t *testing.T, c, cBal *neotest.ContractInvoker) (neotest.Signer, testContainer) {
 	acc := c.NewAccount(t)
 	cnt := dummyContainer(acc)
@@ -190,7 +370,7 @@ func addContainer(t *testing.T, c, cBal *neotest.ContractInvoker) (neotest.Signe
 }
 
 func TestContainerDelete(t *testing.T) {
-	c, cBal, _ := newContainerInvoker(t)
+	c, cBal, cNm := newContainerInvoker(t)
 
 	acc, cnt := addContainer(t, c, cBal)
 	cAcc := c.WithSigners(acc)
@@ -205,7 +385,69 @@ func TestContainerDelete(t *testing.T) {
 		c.Invoke(t, stackitem.Null{}, "delete", cnt.id[:], cnt.sig, cnt.token)
 	})
 
+	// Metadata is kept until the end of the removal epoch.
+	c.Invoke(t, int64(container.RemovedStatus), "status", cnt.id[:])
+	c.Invoke(t, stackitem.NewStruct([]stackitem.Item{
+		stackitem.NewByteArray(cnt.value),
+		stackitem.NewByteArray(cnt.sig),
+		stackitem.NewByteArray(cnt.pub),
+		stackitem.NewByteArray(cnt.token),
+	}), "get", cnt.id[:])
+	checkContainersOf(t, c, acc)
+
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	c.InvokeFail(t, container.NotFoundError, "get", cnt.id[:])
+	c.Invoke(t, int64(container.RemovedStatus), "status", cnt.id[:])
+}
+
+func TestContainerDeleteGracePeriod(t *testing.T) {
+	c, cBal, cNm := newContainerInvoker(t)
+
+	const gracePeriod = 2
+	cNm.Invoke(t, stackitem.Null{}, "setConfig", []byte{1}, container.RemovalGracePeriodKey, int64(gracePeriod))
+
+	acc, cnt := addContainer(t, c, cBal)
+	e := dummyEACL(cnt.id)
+	c.Invoke(t, stackitem.Null{}, "setEACL", e.value, e.sig, e.pub, e.token)
+
+	_, other := addContainer(t, c, cBal)
+	c.Invoke(t, int64(container.LiveStatus), "status", other.id[:])
+	c.Invoke(t, int64(container.NotFoundStatus), "status", randomBytes(32))
+
+	c.Invoke(t, stackitem.Null{}, "delete", cnt.id[:], cnt.sig, cnt.token)
+	c.Invoke(t, int64(container.RemovedStatus), "status", cnt.id[:])
+
+	t.Run("removed container can't be changed", func(t *testing.T) {
+		c.InvokeFail(t, container.RemovedError, "setEACL", e.value, e.sig, e.pub, e.token)
+
+		newOwner := c.NewAccount(t)
+		owner, _ := base58.Decode(address.Uint160ToString(newOwner.ScriptHash()))
+		c.InvokeFail(t, container.RemovedError, "transferOwnership",
+			cnt.id[:], owner, cnt.sig, cnt.pub, cnt.token)
+	})
+
+	t.Run("container ID can't be used again", func(t *testing.T) {
+		balanceMint(t, cBal, acc, containerFee*1, []byte{})
+		c.InvokeFail(t, container.RemovedError, "put", cnt.value, cnt.sig, cnt.pub, cnt.token)
+	})
+
+	for i := int64(1); i <= gracePeriod; i++ {
+		cNm.Invoke(t, stackitem.Null{}, "newEpoch", i)
+		c.Invoke(t, stackitem.NewStruct([]stackitem.Item{
+			stackitem.NewByteArray(e.value),
+			stackitem.NewByteArray(e.sig),
+			stackitem.NewByteArray(e.pub),
+			stackitem.NewByteArray(e.token),
+		}), "eACL", cnt.id[:])
+	}
+
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(gracePeriod+1))
 	c.InvokeFail(t, container.NotFoundError, "get", cnt.id[:])
+	c.InvokeFail(t, container.NotFoundError, "eACL", cnt.id[:])
+	c.Invoke(t, int64(container.RemovedStatus), "status", cnt.id[:])
+	c.InvokeFail(t, container.RemovedError, "put", cnt.value, cnt.sig, cnt.pub, cnt.token)
+
+	c.Invoke(t, int64(container.LiveStatus), "status", other.id[:])
 }
 
 func TestContainerOwner(t *testing.T) {
@@ -223,6 +465,67 @@ func TestContainerOwner(t *testing.T) {
 	c.Invoke(t, stackitem.NewBuffer(owner), "owner", cnt.id[:])
 }
 
+func TestContainerTransferOwnership(t *testing.T) {
+	c, cBal, _ := newContainerInvoker(t)
+
+	acc, cnt := addContainer(t, c, cBal)
+	newAcc := c.NewAccount(t)
+
+	owner, _ := base58.Decode(address.Uint160ToString(acc.ScriptHash()))
+	newOwner, _ := base58.Decode(address.Uint160ToString(newAcc.ScriptHash()))
+	sig, pub, token := randomBytes(64), randomBytes(33), randomBytes(42)
+
+	t.Run("missing container", func(t *testing.T) {
+		id := cnt.id
+		id[0] ^= 0xFF
+		c.InvokeFail(t, container.NotFoundError, "transferOwnership", id[:], newOwner, sig, pub, token)
+		c.InvokeFail(t, container.NotFoundError, "ownershipHistory", id[:])
+	})
+	t.Run("invalid owner", func(t *testing.T) {
+		c.InvokeFail(t, "invalid owner", "transferOwnership", cnt.id[:], newOwner[1:], sig, pub, token)
+	})
+	t.Run("same owner", func(t *testing.T) {
+		c.InvokeFail(t, "container is already owned", "transferOwnership", cnt.id[:], owner, sig, pub, token)
+	})
+
+	cAcc := c.WithSigners(acc)
+	cAcc.InvokeFail(t, common.ErrAlphabetWitnessFailed, "transferOwnership",
+		cnt.id[:], newOwner, sig, pub, token)
+
+	c.Invoke(t, stackitem.NewArray([]stackitem.Item{}), "ownershipHistory", cnt.id[:])
+
+	h := c.Invoke(t, stackitem.Null{}, "transferOwnership", cnt.id[:], newOwner, sig, pub, token)
+	aer := c.CheckHalt(t, h)
+	require.Equal(t, 1, len(aer.Events))
+	require.Equal(t, "TransferOwnershipSuccess", aer.Events[0].Name)
+	require.Equal(t, [][]byte{cnt.id[:], owner, newOwner},
+		stackItemsToBytes(t, aer.Events[0].Item.Value().([]stackitem.Item)))
+
+	c.Invoke(t, stackitem.NewByteArray(newOwner), "owner", cnt.id[:])
+	checkContainersOf(t, c, acc)
+	checkContainersOf(t, c, newAcc, cnt)
+	checkContainersOf(t, c, nil, cnt)
+
+	// The original container structure is kept.
+	c.Invoke(t, stackitem.NewStruct([]stackitem.Item{
+		stackitem.NewByteArray(cnt.value),
+		stackitem.NewByteArray(cnt.sig),
+		stackitem.NewByteArray(cnt.pub),
+		stackitem.NewByteArray(cnt.token),
+	}), "get", cnt.id[:])
+
+	s, err := c.TestInvoke(t, "ownershipHistory", cnt.id[:])
+	require.NoError(t, err)
+	history := s.Pop().Array()
+	require.Equal(t, 1, len(history))
+	require.Equal(t, [][]byte{owner, newOwner, sig, pub, token},
+		stackItemsToBytes(t, history[0].Value().([]stackitem.Item)))
+
+	c.Invoke(t, stackitem.Null{}, "delete", cnt.id[:], cnt.sig, cnt.token)
+	checkContainersOf(t, c, newAcc)
+	checkContainersOf(t, c, nil)
+}
+
 func TestContainerGet(t *testing.T) {
 	c, cBal, _ := newContainerInvoker(t)
 
@@ -289,6 +592,71 @@ func TestContainerSetEACL(t *testing.T) {
 	c.Invoke(t, expected, "eACL", cnt.id[:])
 }
 
+func TestContainerEACLHistory(t *testing.T) {
+	c, cBal, cNm := newContainerInvoker(t)
+
+	acc, cnt := addContainer(t, c, cBal)
+
+	t.Run("missing container", func(t *testing.T) {
+		id := cnt.id
+		id[0] ^= 0xFF
+		c.InvokeFail(t, container.NotFoundError, "removeEACL", id[:], cnt.sig, cnt.token)
+		c.InvokeFail(t, container.NotFoundError, "eACLAt", id[:], int64(0))
+		c.InvokeFail(t, container.NotFoundError, "eACLVersions", id[:])
+	})
+
+	emptyEACL := eacl{value: []byte{}, sig: []byte{}, pub: []byte{}, token: []byte{}}
+	c.Invoke(t, stackitem.NewArray([]stackitem.Item{}), "eACLVersions", cnt.id[:])
+	c.Invoke(t, eACLStackItem(emptyEACL), "eACLAt", cnt.id[:], int64(0))
+
+	e1 := dummyEACL(cnt.id)
+	c.Invoke(t, stackitem.Null{}, "setEACL", e1.value, e1.sig, e1.pub, e1.token)
+
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	e2 := dummyEACL(cnt.id)
+	c.Invoke(t, stackitem.Null{}, "setEACL", e2.value, e2.sig, e2.pub, e2.token)
+
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+	cAcc := c.WithSigners(acc)
+	cAcc.InvokeFail(t, common.ErrAlphabetWitnessFailed, "removeEACL", cnt.id[:], cnt.sig, cnt.token)
+
+	h := c.Invoke(t, stackitem.Null{}, "removeEACL", cnt.id[:], cnt.sig, cnt.token)
+	aer := c.CheckHalt(t, h)
+	require.Equal(t, 1, len(aer.Events))
+	require.Equal(t, "RemoveEACLSuccess", aer.Events[0].Name)
+	require.Equal(t, [][]byte{cnt.id[:]},
+		stackItemsToBytes(t, aer.Events[0].Item.Value().([]stackitem.Item)))
+
+	c.Invoke(t, eACLStackItem(emptyEACL), "eACL", cnt.id[:])
+	c.Invoke(t, eACLStackItem(e1), "eACLAt", cnt.id[:], int64(0))
+	c.Invoke(t, eACLStackItem(e2), "eACLAt", cnt.id[:], int64(1))
+	c.Invoke(t, eACLStackItem(emptyEACL), "eACLAt", cnt.id[:], int64(2))
+	c.Invoke(t, eACLStackItem(emptyEACL), "eACLAt", cnt.id[:], int64(10))
+
+	c.Invoke(t, stackitem.NewArray([]stackitem.Item{
+		stackitem.NewStruct([]stackitem.Item{stackitem.Make(0), eACLStackItem(e1)}),
+		stackitem.NewStruct([]stackitem.Item{stackitem.Make(1), eACLStackItem(e2)}),
+		stackitem.NewStruct([]stackitem.Item{stackitem.Make(2), eACLStackItem(emptyEACL)}),
+	}), "eACLVersions", cnt.id[:])
+
+	t.Run("missing extended ACL", func(t *testing.T) {
+		c.Invoke(t, stackitem.Null{}, "removeEACL", cnt.id[:], cnt.sig, cnt.token)
+
+		s, err := c.TestInvoke(t, "eACLVersions", cnt.id[:])
+		require.NoError(t, err)
+		require.Equal(t, 3, len(s.Pop().Array()))
+	})
+}
+
+func eACLStackItem(e eacl) stackitem.Item {
+	return stackitem.NewStruct([]stackitem.Item{
+		stackitem.NewByteArray(e.value),
+		stackitem.NewByteArray(e.sig),
+		stackitem.NewByteArray(e.pub),
+		stackitem.NewByteArray(e.token),
+	})
+}
+
 func TestContainerSizeEstimation(t *testing.T) {
 	c, cBal, cNm := newContainerInvoker(t)
 
@@ -360,6 +728,361 @@ func TestContainerSizeEstimation(t *testing.T) {
 	checkEstimations(t, c, epoch, cnt, estimation{nodes[1].pub, int64(999)})
 }
 
+func TestContainerUsage(t *testing.T) {
+	c, cBal, cNm := newContainerInvoker(t)
+
+	_, cnt1 := addContainer(t, c, cBal)
+	_, cnt2 := addContainer(t, c, cBal)
+	nodes := []testNodeInfo{
+		newStorageNode(t, c),
+		newStorageNode(t, c),
+		newStorageNode(t, c),
+	}
+	for i := range nodes {
+		cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[i].raw)
+	}
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+
+	sizes1 := []int64{100, 300, 200}
+	for i := range nodes {
+		c.WithSigners(nodes[i].signer).Invoke(t, stackitem.Null{}, "putContainerSize",
+			int64(2), cnt1.id[:], sizes1[i], nodes[i].pub)
+	}
+	sizes2 := []int64{10, 31}
+	for i := range sizes2 {
+		c.WithSigners(nodes[i].signer).Invoke(t, stackitem.Null{}, "putContainerSize",
+			int64(2), cnt2.id[:], sizes2[i], nodes[i].pub)
+	}
+	// the estimation reported again replaces the old one
+	c.WithSigners(nodes[1].signer).Invoke(t, stackitem.Null{}, "putContainerSize",
+		int64(2), cnt2.id[:], int64(30), nodes[1].pub)
+
+	c.Invoke(t, stackitem.Null{}, "containerUsage", cnt1.id[:], int64(2))
+
+	c.WithSigners(nodes[0].signer).InvokeFail(t, common.ErrAlphabetWitnessFailed,
+		"stopContainerEstimation", int64(3))
+	c.Invoke(t, stackitem.Null{}, "stopContainerEstimation", int64(3))
+
+	checkContainerUsage(t, c, cnt1, 2, 200, 200, 3)
+	checkContainerUsage(t, c, cnt2, 2, 20, 20, 2)
+	c.Invoke(t, stackitem.Null{}, "containerUsage", cnt1.id[:], int64(1))
+
+	// Aggregated usage outlives estimations.
+	epoch := int64(3)
+	for ; epoch <= 2+container.UsageCleanupDelta; epoch++ {
+		cNm.Invoke(t, stackitem.Null{}, "newEpoch", epoch)
+	}
+	checkEstimations(t, c, 2, cnt1)
+	checkContainerUsage(t, c, cnt1, 2, 200, 200, 3)
+
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", epoch)
+	c.Invoke(t, stackitem.Null{}, "containerUsage", cnt1.id[:], int64(2))
+	c.Invoke(t, stackitem.Null{}, "containerUsage", cnt2.id[:], int64(2))
+}
+
+func checkContainerUsage(t *testing.T, c *neotest.ContractInvoker, cnt testContainer, epoch, median, mean, reporters int64) {
+	c.Invoke(t, stackitem.NewStruct([]stackitem.Item{
+		stackitem.Make(median),
+		stackitem.Make(mean),
+		stackitem.Make(reporters),
+	}), "containerUsage", cnt.id[:], epoch)
+}
+
+func TestContainerOwnerUsage(t *testing.T) {
+	c, cBal, cNm := newContainerInvoker(t)
+
+	const retention = 1
+	cNm.Invoke(t, stackitem.Null{}, "setConfig", []byte{1}, container.OwnerUsageRetentionKey, int64(retention))
+
+	acc := c.NewAccount(t)
+	cnt1 := dummyContainer(acc)
+	cnt2 := dummyContainer(acc)
+	balanceMint(t, cBal, acc, containerFee*2, []byte{})
+	c.Invoke(t, stackitem.Null{}, "put", cnt1.value, cnt1.sig, cnt1.pub, cnt1.token)
+	c.Invoke(t, stackitem.Null{}, "put", cnt2.value, cnt2.sig, cnt2.pub, cnt2.token)
+	owner, _ := base58.Decode(address.Uint160ToString(acc.ScriptHash()))
+
+	t.Run("invalid owner", func(t *testing.T) {
+		c.InvokeFail(t, "invalid owner", "ownerUsage", owner[1:], int64(2))
+		c.InvokeFail(t, "invalid owner", "ownerUsageHistory", owner[1:])
+	})
+
+	node := newStorageNode(t, c)
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", node.raw)
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+
+	cNode := c.WithSigners(node.signer)
+	cNode.Invoke(t, stackitem.Null{}, "putContainerSize", int64(1), cnt1.id[:], int64(10), node.pub)
+	cNode.Invoke(t, stackitem.Null{}, "putContainerSize", int64(2), cnt1.id[:], int64(100), node.pub)
+	cNode.Invoke(t, stackitem.Null{}, "putContainerSize", int64(2), cnt2.id[:], int64(20), node.pub)
+
+	c.Invoke(t, 0, "ownerUsage", owner, int64(1))
+	c.Invoke(t, stackitem.NewArray([]stackitem.Item{}), "ownerUsageHistory", owner)
+
+	c.Invoke(t, stackitem.Null{}, "stopContainerEstimation", int64(2))
+	c.Invoke(t, stackitem.Null{}, "stopContainerEstimation", int64(3))
+	c.Invoke(t, 10, "ownerUsage", owner, int64(1))
+	c.Invoke(t, 120, "ownerUsage", owner, int64(2))
+
+	t.Run("aggregated again", func(t *testing.T) {
+		c.Invoke(t, stackitem.Null{}, "stopContainerEstimation", int64(3))
+		c.Invoke(t, 120, "ownerUsage", owner, int64(2))
+	})
+
+	c.Invoke(t, stackitem.NewArray([]stackitem.Item{
+		stackitem.NewStruct([]stackitem.Item{stackitem.Make(1), stackitem.Make(10)}),
+		stackitem.NewStruct([]stackitem.Item{stackitem.Make(2), stackitem.Make(120)}),
+	}), "ownerUsageHistory", owner)
+
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(3))
+	c.Invoke(t, 0, "ownerUsage", owner, int64(1))
+	c.Invoke(t, 120, "ownerUsage", owner, int64(2))
+
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(4))
+	c.Invoke(t, stackitem.NewArray([]stackitem.Item{}), "ownerUsageHistory", owner)
+	checkContainerUsage(t, c, cnt1, 2, 100, 100, 1)
+}
+
+func TestContainerQuota(t *testing.T) {
+	c, cBal, cNm := newContainerInvoker(t)
+
+	acc, cnt := addContainer(t, c, cBal)
+	nodes := []testNodeInfo{
+		newStorageNode(t, c),
+		newStorageNode(t, c),
+	}
+	for i := range nodes {
+		cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[i].raw)
+	}
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+
+	sig, pub, token := randomBytes(64), randomBytes(33), randomBytes(42)
+
+	t.Run("missing container", func(t *testing.T) {
+		id := cnt.id
+		id[0] ^= 0xFF
+		c.InvokeFail(t, container.NotFoundError, "setQuota", id[:], int64(100), int64(200), sig, pub, token)
+		c.InvokeFail(t, container.NotFoundError, "clearQuota", id[:], sig, pub, token)
+		c.InvokeFail(t, container.NotFoundError, "quota", id[:])
+		c.InvokeFail(t, container.NotFoundError, "quotaBreaches", id[:])
+	})
+	t.Run("invalid quota", func(t *testing.T) {
+		c.InvokeFail(t, container.ErrInvalidQuota, "setQuota", cnt.id[:], int64(-1), int64(200), sig, pub, token)
+		c.InvokeFail(t, container.ErrInvalidQuota, "setQuota", cnt.id[:], int64(300), int64(200), sig, pub, token)
+	})
+
+	c.WithSigners(acc).InvokeFail(t, common.ErrAlphabetWitnessFailed, "setQuota",
+		cnt.id[:], int64(100), int64(200), sig, pub, token)
+
+	c.Invoke(t, stackitem.Null{}, "quota", cnt.id[:])
+	c.Invoke(t, stackitem.Null{}, "setQuota", cnt.id[:], int64(100), int64(200), sig, pub, token)
+	c.Invoke(t, stackitem.NewStruct([]stackitem.Item{
+		stackitem.Make(100),
+		stackitem.Make(200),
+		stackitem.NewByteArray(sig),
+		stackitem.NewByteArray(pub),
+		stackitem.NewByteArray(token),
+	}), "quota", cnt.id[:])
+
+	putSize := func(t *testing.T, node testNodeInfo, size int64) *state.AppExecResult {
+		h := c.WithSigners(node.signer).Invoke(t, stackitem.Null{}, "putContainerSize",
+			int64(2), cnt.id[:], size, node.pub)
+		return c.CheckHalt(t, h)
+	}
+	checkQuotaExceeded := func(t *testing.T, aer *state.AppExecResult, size int64, hard bool) {
+		require.Equal(t, 1, len(aer.Events))
+		require.Equal(t, "QuotaExceeded", aer.Events[0].Name)
+
+		items := aer.Events[0].Item.Value().([]stackitem.Item)
+		require.Equal(t, 4, len(items))
+		require.Equal(t, [][]byte{cnt.id[:]}, stackItemsToBytes(t, items[:1]))
+		require.Equal(t, int64(2), items[1].Value().(*big.Int).Int64())
+		require.Equal(t, size, items[2].Value().(*big.Int).Int64())
+		actualHard, err := items[3].TryBool()
+		require.NoError(t, err)
+		require.Equal(t, hard, actualHard)
+	}
+
+	aer := putSize(t, nodes[0], 100)
+	require.Equal(t, 0, len(aer.Events))
+
+	aer = putSize(t, nodes[0], 150)
+	checkQuotaExceeded(t, aer, 150, false)
+
+	aer = putSize(t, nodes[1], 120)
+	require.Equal(t, 0, len(aer.Events))
+
+	aer = putSize(t, nodes[1], 250)
+	checkQuotaExceeded(t, aer, 250, true)
+
+	c.Invoke(t, stackitem.NewArray([]stackitem.Item{
+		stackitem.NewStruct([]stackitem.Item{stackitem.Make(2), stackitem.Make(250), stackitem.NewBool(true)}),
+	}), "quotaBreaches", cnt.id[:])
+
+	c.WithSigners(acc).InvokeFail(t, common.ErrAlphabetWitnessFailed, "clearQuota",
+		cnt.id[:], sig, pub, token)
+	c.Invoke(t, stackitem.Null{}, "clearQuota", cnt.id[:], sig, pub, token)
+	c.Invoke(t, stackitem.Null{}, "quota", cnt.id[:])
+
+	aer = putSize(t, nodes[0], 1000)
+	require.Equal(t, 0, len(aer.Events))
+}
+
+func TestContainerQuotaNotaryDisabled(t *testing.T) {
+	c, cBal, alphabet := newNotaryDisabledContainerInvoker(t)
+
+	acc := c.NewAccount(t)
+	cnt := dummyContainer(acc)
+	alphabetVote(t, cBal, alphabet, alphabetThreshold(alphabet), "mint",
+		acc.ScriptHash(), int64(containerFee*len(alphabet)), []byte{})
+	alphabetVote(t, c, alphabet, alphabetThreshold(alphabet), "put",
+		cnt.value, cnt.sig, cnt.pub, cnt.token)
+
+	sig, pub, token := randomBytes(64), randomBytes(33), randomBytes(42)
+
+	h := c.WithSigners(acc).Invoke(t, stackitem.Null{}, "setQuota",
+		cnt.id[:], int64(100), int64(200), sig, pub, token)
+	aer := c.CheckHalt(t, h)
+	require.Equal(t, 1, len(aer.Events))
+	require.Equal(t, "setQuota", aer.Events[0].Name)
+	c.Invoke(t, stackitem.Null{}, "quota", cnt.id[:])
+
+	// Requests with the same limits but different signatures are voted
+	// separately.
+	otherSig := randomBytes(64)
+	threshold := alphabetThreshold(alphabet)
+	alphabetVote(t, c, alphabet, []int{0}, "setQuota",
+		cnt.id[:], int64(100), int64(200), otherSig, pub, token)
+	alphabetVote(t, c, alphabet, threshold[1:], "setQuota",
+		cnt.id[:], int64(100), int64(200), sig, pub, token)
+	c.Invoke(t, stackitem.Null{}, "quota", cnt.id[:])
+
+	alphabetVote(t, c, alphabet, []int{len(threshold)}, "setQuota",
+		cnt.id[:], int64(100), int64(200), sig, pub, token)
+	c.Invoke(t, stackitem.NewStruct([]stackitem.Item{
+		stackitem.Make(100),
+		stackitem.Make(200),
+		stackitem.NewByteArray(sig),
+		stackitem.NewByteArray(pub),
+		stackitem.NewByteArray(token),
+	}), "quota", cnt.id[:])
+}
+
+func TestContainerSizeEstimationBatch(t *testing.T) {
+	c, cBal, cNm := newContainerInvoker(t)
+
+	_, cnt1 := addContainer(t, c, cBal)
+	_, cnt2 := addContainer(t, c, cBal)
+	node := newStorageNode(t, c)
+	otherNode := newStorageNode(t, c)
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", node.raw)
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+
+	sizes := []interface{}{
+		[]interface{}{cnt1.id[:], int64(123)},
+		[]interface{}{cnt2.id[:], int64(456)},
+	}
+
+	t.Run("missing container", func(t *testing.T) {
+		id := cnt1.id
+		id[0] ^= 0xFF
+		c.WithSigners(node.signer).InvokeFail(t, container.NotFoundError, "putContainerSizes",
+			int64(2), append(sizes, []interface{}{id[:], int64(1)}), node.pub)
+	})
+	t.Run("must be witnessed by key in the argument", func(t *testing.T) {
+		c.WithSigners(otherNode.signer).InvokeFail(t, common.ErrWitnessFailed, "putContainerSizes",
+			int64(2), sizes, node.pub)
+	})
+	t.Run("must be storage node", func(t *testing.T) {
+		c.WithSigners(otherNode.signer).InvokeFail(t, "method must be invoked by storage node from network map",
+			"putContainerSizes", int64(2), sizes, otherNode.pub)
+	})
+
+	c.WithSigners(node.signer).Invoke(t, stackitem.Null{}, "putContainerSizes", int64(2), sizes, node.pub)
+
+	s, err := c.TestInvoke(t, "listContainerSizes", int64(2))
+	require.NoError(t, err)
+	ids := s.Pop().Array()
+	require.Equal(t, 2, len(ids))
+
+	actual := make(map[string]int64)
+	for i := range ids {
+		id, err := ids[i].TryBytes()
+		require.NoError(t, err)
+
+		s, err := c.TestInvoke(t, "getContainerSize", id)
+		require.NoError(t, err)
+
+		cnrSizes := s.Pop().Array()
+		cid, err := cnrSizes[0].TryBytes()
+		require.NoError(t, err)
+
+		estimations := cnrSizes[1].Value().([]stackitem.Item)
+		require.Equal(t, 1, len(estimations))
+
+		est := estimations[0].Value().([]stackitem.Item)
+		require.Equal(t, [][]byte{node.pub}, stackItemsToBytes(t, est[:1]))
+		actual[string(cid)] = est[1].Value().(*big.Int).Int64()
+	}
+	require.Equal(t, map[string]int64{
+		string(cnt1.id[:]): 123,
+		string(cnt2.id[:]): 456,
+	}, actual)
+}
+
+func TestContainerSizeEstimationRemoved(t *testing.T) {
+	c, cBal, cNm := newContainerInvoker(t)
+
+	cNm.Invoke(t, stackitem.Null{}, "setConfig", []byte{1}, container.RemovalGracePeriodKey, int64(2))
+
+	_, cnt := addContainer(t, c, cBal)
+	_, other := addContainer(t, c, cBal)
+	node := newStorageNode(t, c)
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", node.raw)
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+
+	c.Invoke(t, stackitem.Null{}, "delete", cnt.id[:], cnt.sig, cnt.token)
+	c.Invoke(t, int64(container.RemovedStatus), "status", cnt.id[:])
+
+	cNode := c.WithSigners(node.signer)
+	cNode.InvokeFail(t, container.RemovedError, "putContainerSize",
+		int64(2), cnt.id[:], int64(123), node.pub)
+	cNode.InvokeFail(t, container.RemovedError, "putContainerSizes", int64(2), []interface{}{
+		[]interface{}{other.id[:], int64(456)},
+		[]interface{}{cnt.id[:], int64(123)},
+	}, node.pub)
+	checkEstimations(t, c, 2, cnt)
+	checkEstimations(t, c, 2, other)
+}
+
+func TestContainerSizeEstimationRotatedKey(t *testing.T) {
+	c, cBal, cNm := newContainerInvoker(t)
+
+	_, cnt := addContainer(t, c, cBal)
+	oldNode := newStorageNode(t, c)
+	newNode := newStorageNode(t, c)
+	otherNode := newStorageNode(t, c)
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", oldNode.raw)
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+
+	cNm.WithSigners(oldNode.signer, newNode.signer, cNm.Committee).Invoke(t, stackitem.Null{},
+		"rotateNodeKey", oldNode.pub, newNode.raw)
+
+	c.WithSigners(otherNode.signer).InvokeFail(t, "method must be invoked by storage node from network map",
+		"putContainerSize", int64(2), cnt.id[:], int64(123), otherNode.pub)
+
+	// previous snapshot still contains the old key
+	c.WithSigners(newNode.signer).Invoke(t, stackitem.Null{}, "putContainerSize",
+		int64(2), cnt.id[:], int64(123), newNode.pub)
+	checkEstimations(t, c, 2, cnt, estimation{newNode.pub, 123})
+}
+
 type estimation struct {
 	from []byte
 	size int64
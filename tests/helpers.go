@@ -2,6 +2,14 @@ package tests
 
 import (
 	"math/rand"
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/interop/storage"
+	"github.com/nspcc-dev/neo-go/pkg/core/native/nativenames"
+	"github.com/nspcc-dev/neo-go/pkg/neotest"
+	"github.com/nspcc-dev/neo-go/pkg/vm"
+	"github.com/nspcc-dev/neo-go/pkg/vm/stackitem"
+	"github.com/stretchr/testify/require"
 )
 
 func randomBytes(n int) []byte {
@@ -9,3 +17,49 @@ func randomBytes(n int) []byte {
 	rand.Read(a)
 	return a
 }
+
+// requireIteratorBytes checks that the stack contains only the iterator over
+// the expected byte arrays in any order.
+func requireIteratorBytes(t *testing.T, s *vm.Stack, expected [][]byte) {
+	require.Equal(t, 1, s.Len())
+
+	actual := stackItemsToBytes(t, iteratorToArray(s.Pop().Value().(*storage.Iterator)))
+	require.ElementsMatch(t, expected, actual)
+}
+
+// alphabetSigners returns signers of the separate alphabet nodes with some GAS
+// to pay for the transactions.
+func alphabetSigners(t *testing.T, e *neotest.Executor) []neotest.Signer {
+	multi, ok := e.Committee.(neotest.MultiSigner)
+	require.True(t, ok)
+
+	_, pubs, ok := vm.ParseMultiSigContract(e.Committee.Script())
+	require.True(t, ok)
+
+	gas := e.CommitteeInvoker(e.NativeHash(t, nativenames.Gas)).WithSigners(e.Validator)
+	signers := make([]neotest.Signer, len(pubs))
+	for i := range signers {
+		signers[i] = multi.Single(i)
+		gas.Invoke(t, true, "transfer",
+			e.Validator.ScriptHash(), signers[i].ScriptHash(), int64(100_0000_0000), nil)
+	}
+	return signers
+}
+
+// alphabetVote invokes the method on behalf of the alphabet nodes with
+// the specified indexes.
+func alphabetVote(t *testing.T, c *neotest.ContractInvoker, alphabet []neotest.Signer, indexes []int, method string, args ...interface{}) {
+	for _, i := range indexes {
+		c.WithSigners(alphabet[i]).Invoke(t, stackitem.Null{}, method, args...)
+	}
+}
+
+// alphabetThreshold returns indexes of the alphabet nodes enough to accept
+// the vote.
+func alphabetThreshold(alphabet []neotest.Signer) []int {
+	indexes := make([]int, len(alphabet)*2/3+1)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
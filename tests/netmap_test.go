@@ -1,12 +1,20 @@
 package tests
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
 	"math/big"
 	"math/rand"
 	"path"
+	"sort"
 	"strings"
 	"testing"
 
+	"github.com/nspcc-dev/neo-go/pkg/core/interop/storage"
+	"github.com/nspcc-dev/neo-go/pkg/core/transaction"
+	"github.com/nspcc-dev/neo-go/pkg/crypto/keys"
 	"github.com/nspcc-dev/neo-go/pkg/encoding/bigint"
 	"github.com/nspcc-dev/neo-go/pkg/neotest"
 	"github.com/nspcc-dev/neo-go/pkg/util"
@@ -21,14 +29,24 @@ import (
 const netmapPath = "../netmap"
 
 func deployNetmapContract(t *testing.T, e *neotest.Executor, addrBalance, addrContainer util.Uint160, config ...interface{}) util.Uint160 {
+	return deployNetmapContractNotary(t, e, false, addrBalance, addrContainer, config...)
+}
+
+func deployNetmapContractNotary(t *testing.T, e *neotest.Executor, notaryDisabled bool,
+	addrBalance, addrContainer util.Uint160, config ...interface{}) util.Uint160 {
 	_, pubs, ok := vm.ParseMultiSigContract(e.Committee.Script())
 	require.True(t, ok)
 
+	keys := make([]interface{}, len(pubs))
+	for i := range pubs {
+		keys[i] = pubs[i]
+	}
+
 	args := make([]interface{}, 5)
-	args[0] = false
+	args[0] = notaryDisabled
 	args[1] = addrBalance
 	args[2] = addrContainer
-	args[3] = []interface{}{pubs[0]}
+	args[3] = keys
 	args[4] = append([]interface{}{}, config...)
 
 	c := neotest.CompileFile(t, e.CommitteeHash, netmapPath, path.Join(netmapPath, "config.yml"))
@@ -51,11 +69,210 @@ func newNetmapInvoker(t *testing.T, config ...interface{}) *neotest.ContractInvo
 	return e.CommitteeInvoker(ctrNetmap.Hash)
 }
 
+func newNotaryDisabledNetmapInvoker(t *testing.T) (*neotest.ContractInvoker, []neotest.Signer) {
+	e := newMultiExecutor(t)
+
+	ctrNNS := neotest.CompileFile(t, e.CommitteeHash, nnsPath, path.Join(nnsPath, "config.yml"))
+	ctrNetmap := neotest.CompileFile(t, e.CommitteeHash, netmapPath, path.Join(netmapPath, "config.yml"))
+	ctrBalance := neotest.CompileFile(t, e.CommitteeHash, balancePath, path.Join(balancePath, "config.yml"))
+	ctrContainer := neotest.CompileFile(t, e.CommitteeHash, containerPath, path.Join(containerPath, "config.yml"))
+
+	e.DeployContract(t, ctrNNS, nil)
+	deployContainerContract(t, e, ctrNetmap.Hash, ctrBalance.Hash, ctrNNS.Hash)
+	deployBalanceContract(t, e, ctrNetmap.Hash, ctrContainer.Hash)
+	deployNetmapContractNotary(t, e, true, ctrBalance.Hash, ctrContainer.Hash)
+	return e.CommitteeInvoker(ctrNetmap.Hash), alphabetSigners(t, e)
+}
+
 func TestDeploySetConfig(t *testing.T) {
-	c := newNetmapInvoker(t, "SomeKey", "TheValue", container.AliasFeeKey, int64(123))
+	c := newNetmapInvoker(t, netmap.UnknownConfigAllowedKey, true,
+		"SomeKey", "TheValue", container.AliasFeeKey, int64(123))
 	c.Invoke(t, "TheValue", "config", "SomeKey")
 	c.Invoke(t, stackitem.NewByteArray(bigint.ToBytes(big.NewInt(123))),
 		"config", container.AliasFeeKey)
+
+	t.Run("allowed last", func(t *testing.T) {
+		c := newNetmapInvoker(t, "SomeKey", "TheValue", netmap.UnknownConfigAllowedKey, true)
+		c.Invoke(t, "TheValue", "config", "SomeKey")
+	})
+}
+
+func TestSetConfig(t *testing.T) {
+	c := newNetmapInvoker(t)
+
+	t.Run("unknown key", func(t *testing.T) {
+		c.InvokeFail(t, netmap.ErrUnknownConfigKey, "setConfig", []byte{1}, "SomeKey", "TheValue")
+	})
+	t.Run("integer", func(t *testing.T) {
+		c.InvokeFail(t, netmap.ErrInvalidConfigValue, "setConfig", []byte{1}, "EpochDuration", int64(0))
+		c.InvokeFail(t, netmap.ErrInvalidConfigValue, "setConfig", []byte{1}, container.RegistrationFeeKey, int64(-1))
+
+		c.Invoke(t, stackitem.Null{}, "setConfig", []byte{1}, "EpochDuration", int64(240))
+		c.Invoke(t, stackitem.NewByteArray(bigint.ToBytes(big.NewInt(240))), "config", "EpochDuration")
+	})
+	t.Run("string", func(t *testing.T) {
+		c.InvokeFail(t, netmap.ErrInvalidConfigValue, "setConfig", []byte{1}, "EigenTrustAlpha", "")
+
+		c.Invoke(t, stackitem.Null{}, "setConfig", []byte{1}, "EigenTrustAlpha", "0.1")
+		c.Invoke(t, "0.1", "config", "EigenTrustAlpha")
+	})
+	t.Run("immutable", func(t *testing.T) {
+		const key = "HomomorphicHashingDisabled"
+
+		c.InvokeFail(t, netmap.ErrInvalidConfigValue, "setConfig", []byte{1}, key, int64(2))
+
+		c.Invoke(t, stackitem.Null{}, "setConfig", []byte{1}, key, true)
+		c.Invoke(t, stackitem.Null{}, "setConfig", []byte{1}, key, true)
+		c.InvokeFail(t, netmap.ErrImmutableConfigKey, "setConfig", []byte{1}, key, false)
+	})
+	t.Run("unknown keys are allowed", func(t *testing.T) {
+		c.Invoke(t, stackitem.Null{}, "setConfig", []byte{1}, netmap.UnknownConfigAllowedKey, true)
+		c.Invoke(t, stackitem.Null{}, "setConfig", []byte{1}, "SomeKey", "TheValue")
+		c.Invoke(t, "TheValue", "config", "SomeKey")
+
+		c.Invoke(t, stackitem.Null{}, "setConfig", []byte{1}, netmap.UnknownConfigAllowedKey, false)
+		c.InvokeFail(t, netmap.ErrUnknownConfigKey, "setConfig", []byte{1}, "OtherKey", "TheValue")
+	})
+}
+
+func TestScheduleConfig(t *testing.T) {
+	c := newNetmapInvoker(t, "EpochDuration", int64(100))
+
+	c.InvokeFail(t, "invalid epoch", "scheduleConfig", []byte{1}, "EpochDuration", int64(200), int64(0))
+	c.InvokeFail(t, netmap.ErrInvalidConfigValue, "scheduleConfig", []byte{1}, "EpochDuration", int64(0), int64(2))
+	c.InvokeFail(t, netmap.ErrUnknownConfigKey, "scheduleConfig", []byte{1}, "SomeKey", "TheValue", int64(2))
+
+	c.Invoke(t, stackitem.Null{}, "scheduleConfig", []byte{1}, "EpochDuration", int64(300), int64(3))
+	c.Invoke(t, stackitem.Null{}, "scheduleConfig", []byte{1}, "EpochDuration", int64(200), int64(2))
+	c.Invoke(t, stackitem.Null{}, "scheduleConfig", []byte{1}, "AuditFee", int64(10), int64(2))
+	checkScheduledConfig(t, c, 2, 2, 3)
+
+	c.InvokeFail(t, "config change not found", "cancelConfig", []byte{1}, "AuditFee", int64(3))
+	c.Invoke(t, stackitem.Null{}, "cancelConfig", []byte{1}, "AuditFee", int64(2))
+	checkScheduledConfig(t, c, 2, 3)
+
+	c.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	c.Invoke(t, configInt(100), "config", "EpochDuration")
+	checkScheduledConfig(t, c, 2, 3)
+
+	c.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+	c.Invoke(t, configInt(200), "config", "EpochDuration")
+	c.Invoke(t, stackitem.Null{}, "config", "AuditFee")
+	checkScheduledConfig(t, c, 3)
+
+	c.Invoke(t, stackitem.Null{}, "newEpoch", int64(3))
+	c.Invoke(t, configInt(300), "config", "EpochDuration")
+	checkScheduledConfig(t, c)
+
+	t.Run("skipped epoch", func(t *testing.T) {
+		c.Invoke(t, stackitem.Null{}, "scheduleConfig", []byte{1}, "AuditFee", int64(10), int64(4))
+		c.Invoke(t, stackitem.Null{}, "newEpoch", int64(5))
+		c.Invoke(t, configInt(10), "config", "AuditFee")
+		checkScheduledConfig(t, c)
+	})
+
+	t.Run("immutable value is set meanwhile", func(t *testing.T) {
+		const key = "HomomorphicHashingDisabled"
+
+		c.Invoke(t, stackitem.Null{}, "scheduleConfig", []byte{1}, key, true, int64(6))
+		c.Invoke(t, stackitem.Null{}, "setConfig", []byte{1}, key, false)
+		c.Invoke(t, stackitem.Null{}, "newEpoch", int64(6))
+		c.Invoke(t, []byte{0}, "config", key)
+		checkScheduledConfig(t, c)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		c.Invoke(t, configInt(100), "configAt", "EpochDuration", int64(0))
+		c.Invoke(t, configInt(100), "configAt", "EpochDuration", int64(1))
+		c.Invoke(t, configInt(200), "configAt", "EpochDuration", int64(2))
+		c.Invoke(t, configInt(300), "configAt", "EpochDuration", int64(3))
+		c.Invoke(t, configInt(300), "configAt", "EpochDuration", int64(10))
+
+		c.Invoke(t, stackitem.Null{}, "configAt", "AuditFee", int64(3))
+		c.Invoke(t, configInt(10), "configAt", "AuditFee", int64(5))
+	})
+}
+
+func TestScheduleConfigNotaryDisabled(t *testing.T) {
+	c, alphabet := newNotaryDisabledNetmapInvoker(t)
+	indexes := alphabetThreshold(alphabet)
+	last := len(indexes) - 1
+
+	c.InvokeFail(t, "invoked by non inner ring node",
+		"scheduleConfig", []byte{1}, "EpochDuration", int64(200), int64(2))
+
+	alphabetVote(t, c, alphabet, indexes[:last], "scheduleConfig", []byte{1}, "EpochDuration", int64(200), int64(2))
+	checkScheduledConfig(t, c)
+	alphabetVote(t, c, alphabet, indexes[last:], "scheduleConfig", []byte{1}, "EpochDuration", int64(200), int64(2))
+	checkScheduledConfig(t, c, 2)
+
+	c.InvokeFail(t, "invoked by non inner ring node", "cancelConfig", []byte{2}, "EpochDuration", int64(2))
+
+	alphabetVote(t, c, alphabet, indexes[:last], "cancelConfig", []byte{2}, "EpochDuration", int64(2))
+	checkScheduledConfig(t, c, 2)
+	alphabetVote(t, c, alphabet, indexes[last:], "cancelConfig", []byte{2}, "EpochDuration", int64(2))
+	checkScheduledConfig(t, c)
+}
+
+func configInt(n int64) stackitem.Item {
+	return stackitem.NewByteArray(bigint.ToBytes(big.NewInt(n)))
+}
+
+func checkScheduledConfig(t *testing.T, c *neotest.ContractInvoker, epochs ...int64) {
+	s, err := c.TestInvoke(t, "listScheduledConfig")
+	require.NoError(t, err)
+	require.Equal(t, 1, s.Len())
+
+	arr, ok := s.Pop().Value().([]stackitem.Item)
+	require.True(t, ok, "expected array")
+	require.Equal(t, len(epochs), len(arr))
+
+	for i := range arr {
+		change := arr[i].Value().([]stackitem.Item)
+		require.Equal(t, 3, len(change), "expected epoch, key and value fields")
+
+		epoch, err := change[0].TryInteger()
+		require.NoError(t, err)
+		require.Equal(t, epochs[i], epoch.Int64())
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	c := newNetmapInvoker(t)
+
+	s, err := c.TestInvoke(t, "configSchema")
+	require.NoError(t, err)
+	require.Equal(t, 1, s.Len())
+
+	arr, ok := s.Pop().Value().([]stackitem.Item)
+	require.True(t, ok, "expected array")
+
+	var found bool
+	for i := range arr {
+		entry := arr[i].Value().([]stackitem.Item)
+		require.Equal(t, 5, len(entry), "expected key, type, min, max and mutable fields")
+
+		key, err := entry[0].TryBytes()
+		require.NoError(t, err)
+		if string(key) != netmap.CandidateExpirationKey {
+			continue
+		}
+
+		found = true
+		typ, err := entry[1].TryInteger()
+		require.NoError(t, err)
+		require.Equal(t, int64(netmap.IntegerConfigType), typ.Int64())
+
+		min, err := entry[2].TryInteger()
+		require.NoError(t, err)
+		require.Equal(t, int64(0), min.Int64())
+		require.Equal(t, stackitem.Null{}, entry[3])
+
+		mutable, err := entry[4].TryBool()
+		require.NoError(t, err)
+		require.True(t, mutable)
+	}
+	require.True(t, found, "%s is missing from the schema", netmap.CandidateExpirationKey)
 }
 
 type testNodeInfo struct {
@@ -64,20 +281,44 @@ type testNodeInfo struct {
 	raw    []byte
 }
 
-func dummyNodeInfo(acc neotest.Signer) testNodeInfo {
-	ni := make([]byte, 66)
-	rand.Read(ni)
-
+func dummyNodeInfo(acc neotest.Signer, attrs ...string) testNodeInfo {
 	s := acc.(neotest.SingleSigner)
 	pub := s.Account().PrivateKey().PublicKey().Bytes()
-	copy(ni[2:], pub)
+	addr := fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", rand.Intn(65536))
 	return testNodeInfo{
 		signer: s,
 		pub:    pub,
-		raw:    ni,
+		raw:    marshalNodeInfo(pub, []string{addr}, attrs...),
 	}
 }
 
+// marshalNodeInfo returns stable marshaled netmap.NodeInfo structure.
+// Attributes are specified as key-value pairs.
+func marshalNodeInfo(pub []byte, addrs []string, attrs ...string) []byte {
+	ni := appendProtoBytes(nil, 1, pub)
+	for i := range addrs {
+		ni = appendProtoBytes(ni, 2, []byte(addrs[i]))
+	}
+	for i := 0; i < len(attrs); i += 2 {
+		attr := appendProtoBytes(nil, 1, []byte(attrs[i]))
+		attr = appendProtoBytes(attr, 2, []byte(attrs[i+1]))
+		ni = appendProtoBytes(ni, 3, attr)
+	}
+	return ni
+}
+
+func appendProtoBytes(buf []byte, field int, data []byte) []byte {
+	buf = appendUvarint(buf, uint64(field<<3|2))
+	buf = appendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
 func newStorageNode(t *testing.T, c *neotest.ContractInvoker) testNodeInfo {
 	return dummyNodeInfo(c.NewAccount(t))
 }
@@ -97,7 +338,7 @@ func TestAddPeer(t *testing.T) {
 	aer := cAcc.CheckHalt(t, h)
 	require.Equal(t, 0, len(aer.Events))
 
-	dummyInfo.raw[0] ^= 0xFF
+	dummyInfo.raw[len(dummyInfo.raw)-1] ^= 0xFF
 	h = cAcc.Invoke(t, stackitem.Null{}, "addPeer", dummyInfo.raw)
 	aer = cAcc.CheckHalt(t, h)
 	require.Equal(t, 0, len(aer.Events))
@@ -106,6 +347,45 @@ func TestAddPeer(t *testing.T) {
 	c.Invoke(t, stackitem.Null{}, "addPeerIR", dummyInfo.raw)
 }
 
+func TestAddPeerValidation(t *testing.T) {
+	c := newNetmapInvoker(t)
+
+	acc := c.NewAccount(t)
+	pub := acc.(neotest.SingleSigner).Account().PrivateKey().PublicKey().Bytes()
+	addrs := []string{"/ip4/127.0.0.1/tcp/8080"}
+
+	c.Invoke(t, stackitem.Null{}, "addPeerIR", marshalNodeInfo(pub, addrs, "Continent", "Europe"))
+
+	testCases := []struct {
+		name string
+		err  string
+		ni   []byte
+	}{
+		{"empty", netmap.ErrInvalidNodeKey, []byte{}},
+		{"truncated key", netmap.ErrInvalidNodeKey, marshalNodeInfo(pub, addrs)[:20]},
+		{"short key", netmap.ErrInvalidNodeKey, marshalNodeInfo(pub[:32], addrs)},
+		{"uncompressed key", netmap.ErrInvalidNodeKey,
+			marshalNodeInfo(acc.(neotest.SingleSigner).Account().PrivateKey().PublicKey().UncompressedBytes(), addrs)},
+		{"key is not the first field", netmap.ErrInvalidNodeKey,
+			append(appendProtoBytes(nil, 2, []byte(addrs[0])), appendProtoBytes(nil, 1, pub)...)},
+		{"no addresses", netmap.ErrMissingNodeAddress, marshalNodeInfo(pub, nil)},
+		{"empty address", netmap.ErrMissingNodeAddress, marshalNodeInfo(pub, []string{""})},
+		{"truncated address", netmap.ErrInvalidNodeInfo, marshalNodeInfo(pub, addrs)[:40]},
+		{"unknown field", netmap.ErrInvalidNodeInfo,
+			appendProtoBytes(marshalNodeInfo(pub, addrs), 10, []byte{1})},
+		{"wrong order", netmap.ErrInvalidNodeInfo,
+			appendProtoBytes(marshalNodeInfo(pub, addrs, "Continent", "Europe"), 2, []byte(addrs[0]))},
+		{"empty attribute value", netmap.ErrInvalidNodeAttribute, marshalNodeInfo(pub, addrs, "Continent", "")},
+		{"empty attribute key", netmap.ErrInvalidNodeAttribute, marshalNodeInfo(pub, addrs, "", "Europe")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c.InvokeFail(t, tc.err, "addPeerIR", tc.ni)
+			c.WithSigners(acc).InvokeFail(t, tc.err, "addPeer", tc.ni)
+		})
+	}
+}
+
 func TestNewEpoch(t *testing.T) {
 	rand.Seed(42)
 
@@ -151,6 +431,11 @@ func TestNewEpoch(t *testing.T) {
 		require.Equal(t, 1, s.Len())
 		checkSnapshot(t, s, nodes[i])
 
+		s, err = cNm.TestInvoke(t, "listNetmap")
+		require.NoError(t, err)
+		require.Equal(t, 1, s.Len())
+		checkSnapshotIterator(t, s, nodes[i])
+
 		for j := 0; j <= i && j < netmap.DefaultSnapshotCount; j++ {
 			t.Logf("Epoch: %d, diff: %d", i, j)
 			checkSnapshotAt(t, j, cNm, nodes[i-j])
@@ -233,6 +518,32 @@ func TestUpdateSnapshotCount(t *testing.T) {
 		_, err = cNm.TestInvoke(t, "snapshot", int64(newCount))
 		require.Error(t, err)
 	})
+	t.Run("increase size, read archived snapshots", func(t *testing.T) {
+		// Before: S-x .. S S-old ...
+		// After : S-x .. S nil nil S-old ..., nil snapshots are read from the archive
+		const (
+			epochCount = netmap.DefaultSnapshotCount + netmap.DefaultSnapshotCount/2
+			newCount   = netmap.DefaultSnapshotCount + 3
+		)
+
+		cNm := newNetmapInvoker(t, netmap.SnapshotArchiveRetentionKey, int64(epochCount))
+		nodes := prepare(t, cNm, epochCount)
+
+		cNm.Invoke(t, stackitem.Null{}, "updateSnapshotCount", newCount)
+
+		for i := newCount - 3; i < newCount; i++ {
+			checkSnapshotAt(t, i, cNm, nil)
+
+			epoch := int64(epochCount - i)
+			s, err := cNm.TestInvoke(t, "snapshotByEpoch", epoch)
+			require.NoError(t, err)
+			checkSnapshot(t, s, nodes[epoch-1])
+
+			s, err = cNm.TestInvoke(t, "listSnapshotByEpoch", epoch)
+			require.NoError(t, err)
+			checkSnapshotIterator(t, s, nodes[epoch-1])
+		}
+	})
 	t.Run("decrease size, small decrease", func(t *testing.T) {
 		// Before: S-x .. S S-old ... ...
 		// After : S-x .. S S-new ...
@@ -277,11 +588,475 @@ func TestUpdateSnapshotCount(t *testing.T) {
 	})
 }
 
+func TestSnapshotArchive(t *testing.T) {
+	const (
+		retention  = netmap.DefaultSnapshotCount + 3
+		epochCount = retention + 3
+	)
+
+	cNm := newNetmapInvoker(t, netmap.SnapshotArchiveRetentionKey, int64(retention))
+	nodes := make([]testNodeInfo, epochCount)
+	for i := range nodes {
+		nodes[i] = newStorageNode(t, cNm)
+		cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[i].raw)
+		cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(i+1))
+	}
+
+	for epoch := 1; epoch <= epochCount; epoch++ {
+		if epoch <= epochCount-retention {
+			_, err := cNm.TestInvoke(t, "snapshotByEpoch", int64(epoch))
+			require.Error(t, err)
+			require.True(t, strings.Contains(err.Error(), "incorrect diff"))
+			continue
+		}
+
+		s, err := cNm.TestInvoke(t, "snapshotByEpoch", int64(epoch))
+		require.NoError(t, err)
+		checkSnapshot(t, s, nodes[:epoch])
+
+		s, err = cNm.TestInvoke(t, "listSnapshotByEpoch", int64(epoch))
+		require.NoError(t, err)
+		checkSnapshotIterator(t, s, nodes[:epoch])
+	}
+}
+
+func TestNetmapDiff(t *testing.T) {
+	cNm := newNetmapInvoker(t)
+
+	nodes := []testNodeInfo{
+		newStorageNode(t, cNm),
+		newStorageNode(t, cNm),
+		newStorageNode(t, cNm),
+	}
+
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[0].raw)
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[1].raw)
+	h := cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	checkNetmapChanged(t, cNm, h, [][]byte{nodes[0].pub, nodes[1].pub}, nil)
+
+	cNm.Invoke(t, stackitem.Null{}, "updateStateIR", int64(netmap.OfflineState), nodes[0].pub)
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[2].raw)
+	h = cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+	checkNetmapChanged(t, cNm, h, [][]byte{nodes[2].pub}, [][]byte{nodes[0].pub})
+
+	h = cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(3))
+	checkNetmapChanged(t, cNm, h, nil, nil)
+
+	s, err := cNm.TestInvoke(t, "netmapDiff", int64(1), int64(3))
+	require.NoError(t, err)
+	diff := s.Pop().Value().([]stackitem.Item)
+	require.ElementsMatch(t, [][]byte{nodes[2].pub}, stackItemsToBytes(t, diff[0].Value().([]stackitem.Item)))
+	require.ElementsMatch(t, [][]byte{nodes[0].pub}, stackItemsToBytes(t, diff[1].Value().([]stackitem.Item)))
+
+	s, err = cNm.TestInvoke(t, "netmapDiff", int64(0), int64(1))
+	require.NoError(t, err)
+	diff = s.Pop().Value().([]stackitem.Item)
+	require.ElementsMatch(t, [][]byte{nodes[0].pub, nodes[1].pub}, stackItemsToBytes(t, diff[0].Value().([]stackitem.Item)))
+	require.Equal(t, 0, len(diff[1].Value().([]stackitem.Item)))
+
+	_, err = cNm.TestInvoke(t, "netmapDiff", int64(1), int64(4))
+	require.Error(t, err)
+}
+
+func checkNetmapChanged(t *testing.T, c *neotest.ContractInvoker, h util.Uint256, added, removed [][]byte) {
+	aer := c.CheckHalt(t, h)
+	for _, ev := range aer.Events {
+		if ev.Name != "NetmapChanged" {
+			continue
+		}
+
+		arr := ev.Item.Value().([]stackitem.Item)
+		require.ElementsMatch(t, added, stackItemsToBytes(t, arr[1].Value().([]stackitem.Item)))
+		require.ElementsMatch(t, removed, stackItemsToBytes(t, arr[2].Value().([]stackitem.Item)))
+		return
+	}
+	require.FailNow(t, "NetmapChanged notification is missing")
+}
+
+func stackItemsToBytes(t *testing.T, items []stackitem.Item) [][]byte {
+	var res [][]byte
+	for i := range items {
+		b, err := items[i].TryBytes()
+		require.NoError(t, err)
+		res = append(res, b)
+	}
+	return res
+}
+
+func TestCandidateExpiration(t *testing.T) {
+	cNm := newNetmapInvoker(t, netmap.CandidateExpirationKey, int64(1))
+
+	nodes := []testNodeInfo{
+		newStorageNode(t, cNm),
+		newStorageNode(t, cNm),
+		newStorageNode(t, cNm),
+	}
+	for i := range nodes {
+		cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[i].raw)
+	}
+	cNm.Invoke(t, stackitem.Null{}, "updateStateIR", int64(netmap.MaintenanceState), nodes[2].pub)
+
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	checkNetmapCandidates(t, cNm, 3)
+
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[1].raw)
+	h := cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+	checkNetmapCandidates(t, cNm, 2)
+
+	aer := cNm.CheckHalt(t, h)
+	var expired [][]byte
+	for _, ev := range aer.Events {
+		if ev.Name == "CandidateExpired" {
+			expired = append(expired, ev.Item.Value().([]stackitem.Item)[0].Value().([]byte))
+		}
+	}
+	require.Equal(t, [][]byte{nodes[0].pub}, expired)
+
+	s, err := cNm.TestInvoke(t, "netmap")
+	require.NoError(t, err)
+	checkSnapshot(t, s, nodes[1:])
+}
+
+func TestAttributeIndex(t *testing.T) {
+	cNm := newNetmapInvoker(t)
+
+	nodes := []testNodeInfo{
+		dummyNodeInfo(cNm.NewAccount(t), "Continent", "Europe", "UN-LOCODE", "RU MOW"),
+		dummyNodeInfo(cNm.NewAccount(t), "Continent", "Europe", "UN-LOCODE", "RU LED"),
+		dummyNodeInfo(cNm.NewAccount(t), "Continent", "Asia"),
+	}
+	for i := range nodes {
+		cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[i].raw)
+	}
+
+	checkCandidatesByAttribute(t, cNm, "Continent", "Europe", nodes[0], nodes[1])
+	checkCandidatesByAttribute(t, cNm, "Continent", "Asia", nodes[2])
+	checkCandidatesByAttribute(t, cNm, "UN-LOCODE", "RU MOW", nodes[0])
+	checkCandidatesByAttribute(t, cNm, "Continent", "Africa")
+	checkNetmapByAttribute(t, cNm, "Continent", "Europe")
+
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	checkNetmapByAttribute(t, cNm, "Continent", "Europe", nodes[0], nodes[1])
+	checkNetmapByAttribute(t, cNm, "UN-LOCODE", "RU LED", nodes[1])
+
+	t.Run("attributes are replaced on re-add", func(t *testing.T) {
+		updated := nodes[1]
+		updated.raw = marshalNodeInfo(updated.pub, []string{"/ip4/127.0.0.1/tcp/8080"}, "Continent", "Asia")
+		cNm.Invoke(t, stackitem.Null{}, "addPeerIR", updated.raw)
+
+		checkCandidatesByAttribute(t, cNm, "Continent", "Europe", nodes[0])
+		checkCandidatesByAttribute(t, cNm, "UN-LOCODE", "RU LED")
+		checkCandidatesByAttribute(t, cNm, "Continent", "Asia", updated, nodes[2])
+
+		// current network map is changed only in the next epoch
+		checkNetmapByAttribute(t, cNm, "Continent", "Europe", nodes[0], nodes[1])
+	})
+
+	t.Run("attributes are removed with the candidate", func(t *testing.T) {
+		cNm.Invoke(t, stackitem.Null{}, "updateStateIR", int64(netmap.OfflineState), nodes[0].pub)
+
+		checkCandidatesByAttribute(t, cNm, "Continent", "Europe")
+		checkCandidatesByAttribute(t, cNm, "UN-LOCODE", "RU MOW")
+
+		cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+		checkNetmapByAttribute(t, cNm, "Continent", "Europe")
+		checkNetmapByAttribute(t, cNm, "Continent", "Asia", nodes[1], nodes[2])
+	})
+}
+
+func checkCandidatesByAttribute(t *testing.T, cNm *neotest.ContractInvoker, key, value string, nodes ...testNodeInfo) {
+	checkNodesByAttribute(t, cNm, "listCandidatesByAttribute", key, value, nodes)
+}
+
+func checkNetmapByAttribute(t *testing.T, cNm *neotest.ContractInvoker, key, value string, nodes ...testNodeInfo) {
+	checkNodesByAttribute(t, cNm, "listNetmapByAttribute", key, value, nodes)
+}
+
+func checkNodesByAttribute(t *testing.T, cNm *neotest.ContractInvoker, method, key, value string, nodes []testNodeInfo) {
+	s, err := cNm.TestInvoke(t, method, key, value)
+	require.NoError(t, err)
+	requireIteratorBytes(t, s, testNodeKeys(nodes))
+}
+
+func TestNodeStats(t *testing.T) {
+	cNm := newNetmapInvoker(t)
+
+	nodes := []testNodeInfo{
+		newStorageNode(t, cNm),
+		newStorageNode(t, cNm),
+		newStorageNode(t, cNm),
+	}
+	for i := range nodes {
+		cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[i].raw)
+	}
+	cNm.Invoke(t, stackitem.Null{}, "updateStateIR", int64(netmap.MaintenanceState), nodes[2].pub)
+
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	checkNodeStats(t, cNm, nodes[0], 1, 1, 1)
+	checkNodeStats(t, cNm, nodes[1], 1, 1, 1)
+	cNm.Invoke(t, stackitem.Null{}, "nodeStats", nodes[2].pub)
+
+	cNm.Invoke(t, stackitem.Null{}, "updateStateIR", int64(netmap.OfflineState), nodes[1].pub)
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+	checkNodeStats(t, cNm, nodes[0], 1, 2, 2)
+	checkNodeStats(t, cNm, nodes[1], 1, 1, 1)
+
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[1].raw)
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[2].raw)
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(4))
+	checkNodeStats(t, cNm, nodes[0], 1, 4, 3)
+	checkNodeStats(t, cNm, nodes[1], 1, 4, 2)
+	checkNodeStats(t, cNm, nodes[2], 4, 4, 1)
+
+	s, err := cNm.TestInvoke(t, "listNodeStats")
+	require.NoError(t, err)
+	require.Equal(t, 1, s.Len())
+	require.Equal(t, len(nodes), len(iteratorToArray(s.Pop().Value().(*storage.Iterator))))
+}
+
+func checkNodeStats(t *testing.T, cNm *neotest.ContractInvoker, node testNodeInfo, first, last, count int64) {
+	cNm.Invoke(t, stackitem.NewStruct([]stackitem.Item{
+		stackitem.Make(first),
+		stackitem.Make(last),
+		stackitem.Make(count),
+	}), "nodeStats", node.pub)
+}
+
+func TestNodeLookup(t *testing.T) {
+	cNm := newNetmapInvoker(t)
+
+	nodes := []testNodeInfo{
+		newStorageNode(t, cNm),
+		newStorageNode(t, cNm),
+	}
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[0].raw)
+
+	s, err := cNm.TestInvoke(t, "candidate", nodes[0].pub)
+	require.NoError(t, err)
+	candidate := s.Pop().Value().([]stackitem.Item)
+	require.Equal(t, 1, len(candidate[0].Value().([]stackitem.Item)), "expected single field")
+	require.Equal(t, nodes[0].raw, candidate[0].Value().([]stackitem.Item)[0].Value())
+	require.Equal(t, stackitem.Make(int64(netmap.OnlineState)), candidate[1])
+
+	cNm.Invoke(t, stackitem.Null{}, "candidate", nodes[1].pub)
+
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	cNm.Invoke(t, stackitem.Null{}, "updateStateIR", int64(netmap.MaintenanceState), nodes[0].pub)
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[1].raw)
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+
+	checkSnapshotNode(t, cNm, 1, nodes[0], int64(netmap.OnlineState))
+	checkSnapshotNode(t, cNm, 2, nodes[0], int64(netmap.MaintenanceState))
+	checkSnapshotNode(t, cNm, 2, nodes[1], int64(netmap.OnlineState))
+	cNm.Invoke(t, stackitem.Null{}, "snapshotNodeByEpoch", int64(1), nodes[1].pub)
+
+	_, err = cNm.TestInvoke(t, "snapshotNodeByEpoch", int64(3), nodes[0].pub)
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "incorrect diff"))
+}
+
+func checkSnapshotNode(t *testing.T, cNm *neotest.ContractInvoker, epoch int64, node testNodeInfo, state int64) {
+	cNm.Invoke(t, stackitem.NewStruct([]stackitem.Item{
+		stackitem.NewByteArray(node.raw),
+		stackitem.Make(state),
+	}), "snapshotNodeByEpoch", epoch, node.pub)
+}
+
+func TestSnapshotHash(t *testing.T) {
+	cNm := newNetmapInvoker(t)
+
+	nodes := []testNodeInfo{
+		newStorageNode(t, cNm),
+		newStorageNode(t, cNm),
+		newStorageNode(t, cNm),
+	}
+	for i := range nodes {
+		cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[i].raw)
+	}
+
+	h := cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	expected := snapshotHash(nodes)
+	cNm.Invoke(t, stackitem.NewByteArray(expected), "snapshotHash", int64(1))
+	cNm.Invoke(t, stackitem.Null{}, "snapshotHash", int64(0))
+
+	aer := cNm.CheckHalt(t, h)
+	ev := aer.Events[len(aer.Events)-1]
+	require.Equal(t, "NewEpoch", ev.Name)
+	require.Equal(t, 1, len(ev.Item.Value().([]stackitem.Item)), "expected epoch only")
+
+	ev = aer.Events[len(aer.Events)-2]
+	require.Equal(t, "NewEpochSnapshotHash", ev.Name)
+	require.Equal(t, expected, ev.Item.Value().([]stackitem.Item)[1].Value())
+
+	cNm.Invoke(t, stackitem.Null{}, "updateStateIR", int64(netmap.OfflineState), nodes[1].pub)
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+	cNm.Invoke(t, stackitem.NewByteArray(snapshotHash([]testNodeInfo{nodes[0], nodes[2]})), "snapshotHash", int64(2))
+
+	// hashes are kept after the snapshots are overwritten
+	for i := 3; i <= netmap.DefaultSnapshotCount+2; i++ {
+		cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(i))
+	}
+	cNm.Invoke(t, stackitem.NewByteArray(expected), "snapshotHash", int64(1))
+}
+
+// newEpochGasLimit is the GAS budget of the NewEpoch notary request.
+const newEpochGasLimit = 100_0000_0000
+
+func TestNewEpochLargeNetmap(t *testing.T) {
+	const nodeCount = 100
+
+	cNm := newNetmapInvoker(t, netmap.SnapshotArchiveRetentionKey, int64(netmap.DefaultSnapshotCount))
+
+	nodes := make([]testNodeInfo, nodeCount)
+	txs := make([]*transaction.Transaction, nodeCount)
+	for i := range nodes {
+		p, err := keys.NewPrivateKey()
+		require.NoError(t, err)
+
+		pub := p.PublicKey().Bytes()
+		addr := fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", 10000+i)
+		nodes[i] = testNodeInfo{
+			pub: pub,
+			raw: marshalNodeInfo(pub, []string{addr}, "Continent", "Europe"),
+		}
+		txs[i] = cNm.PrepareInvoke(t, "addPeerIR", nodes[i].raw)
+	}
+	cNm.AddNewBlock(t, txs...)
+	for i := range txs {
+		cNm.CheckHalt(t, txs[i].Hash(), stackitem.Null{})
+	}
+
+	newEpoch := func(t *testing.T, epoch int64, added, removed []testNodeInfo) {
+		h := cNm.Invoke(t, stackitem.Null{}, "newEpoch", epoch)
+		aer := cNm.CheckHalt(t, h)
+		require.Less(t, aer.GasConsumed, int64(newEpochGasLimit), "epoch %d", epoch)
+		checkNetmapChanged(t, cNm, h, testNodeKeys(added), testNodeKeys(removed))
+	}
+
+	newEpoch(t, 1, nodes, nil)
+	newEpoch(t, 2, nil, nil)
+
+	for i := 0; i < nodeCount/10; i++ {
+		cNm.Invoke(t, stackitem.Null{}, "updateStateIR", int64(netmap.OfflineState), nodes[i].pub)
+	}
+	newEpoch(t, 3, nil, nodes[:nodeCount/10])
+
+	s, err := cNm.TestInvoke(t, "netmap")
+	require.NoError(t, err)
+	checkSnapshot(t, s, nodes[nodeCount/10:])
+	cNm.Invoke(t, stackitem.NewByteArray(snapshotHash(nodes[nodeCount/10:])), "snapshotHash", int64(3))
+}
+
+func TestNewEpochSingleSnapshot(t *testing.T) {
+	cNm := newNetmapInvoker(t)
+	cNm.Invoke(t, stackitem.Null{}, "updateSnapshotCount", int64(1))
+
+	nodes := []testNodeInfo{
+		newStorageNode(t, cNm),
+		newStorageNode(t, cNm),
+		newStorageNode(t, cNm),
+	}
+
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[0].raw)
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[1].raw)
+	h := cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	checkNetmapChanged(t, cNm, h, [][]byte{nodes[0].pub, nodes[1].pub}, nil)
+
+	cNm.Invoke(t, stackitem.Null{}, "updateStateIR", int64(netmap.OfflineState), nodes[0].pub)
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", nodes[2].raw)
+	h = cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(2))
+	checkNetmapChanged(t, cNm, h, [][]byte{nodes[2].pub}, [][]byte{nodes[0].pub})
+
+	checkSnapshotAt(t, 0, cNm, []testNodeInfo{nodes[1], nodes[2]})
+}
+
+func testNodeKeys(nodes []testNodeInfo) [][]byte {
+	var res [][]byte
+	for i := range nodes {
+		res = append(res, nodes[i].pub)
+	}
+	return res
+}
+
+func snapshotHash(nodes []testNodeInfo) []byte {
+	sorted := append([]testNodeInfo(nil), nodes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].pub, sorted[j].pub) < 0
+	})
+
+	var hashes []byte
+	for i := range sorted {
+		h := sha256.Sum256(sorted[i].raw)
+		hashes = append(hashes, h[:]...)
+	}
+
+	h := sha256.Sum256(hashes)
+	return h[:]
+}
+
+func TestEpochTiming(t *testing.T) {
+	cNm := newNetmapInvoker(t)
+
+	type timing struct {
+		epoch, block, timestamp int64
+	}
+
+	getTiming := func(t *testing.T, epoch int64) timing {
+		s, err := cNm.TestInvoke(t, "lastEpochBlock")
+		require.NoError(t, err)
+
+		// the epoch is applied by the block following the last epoch block
+		block := s.Pop().BigInt().Int64()
+		applied := block + 1
+		if epoch == 0 {
+			applied = 0
+		}
+
+		return timing{
+			epoch:     epoch,
+			block:     block,
+			timestamp: int64(cNm.GetBlockByIndex(t, int(applied)).Timestamp),
+		}
+	}
+
+	timings := []timing{getTiming(t, 0)}
+	for _, epoch := range []int64{1, 2, 5} {
+		cNm.AddNewBlock(t)
+		cNm.AddNewBlock(t)
+		cNm.Invoke(t, stackitem.Null{}, "newEpoch", epoch)
+		timings = append(timings, getTiming(t, epoch))
+	}
+
+	for _, tm := range timings {
+		cNm.Invoke(t, stackitem.NewStruct([]stackitem.Item{
+			stackitem.Make(tm.epoch),
+			stackitem.Make(tm.block),
+			stackitem.Make(tm.timestamp),
+		}), "epochTiming", tm.epoch)
+
+		cNm.Invoke(t, tm.epoch, "epochByBlock", tm.block)
+		cNm.Invoke(t, tm.epoch, "epochByBlock", tm.block+1)
+		cNm.Invoke(t, tm.epoch, "epochByTime", tm.timestamp)
+		cNm.Invoke(t, tm.epoch, "epochByTime", tm.timestamp+1)
+	}
+	cNm.Invoke(t, stackitem.Null{}, "epochTiming", int64(3))
+
+	cNm.Invoke(t, int64(2), "epochByBlock", timings[3].block-1)
+	cNm.Invoke(t, int64(5), "epochByBlock", timings[3].block+100)
+	cNm.Invoke(t, int64(2), "epochByTime", timings[3].timestamp-1)
+	cNm.Invoke(t, stackitem.Null{}, "epochByTime", timings[0].timestamp-1)
+}
+
 func checkSnapshotAt(t *testing.T, epoch int, cNm *neotest.ContractInvoker, nodes []testNodeInfo) {
 	s, err := cNm.TestInvoke(t, "snapshot", int64(epoch))
 	require.NoError(t, err)
 	require.Equal(t, 1, s.Len())
 	checkSnapshot(t, s, nodes)
+
+	s, err = cNm.TestInvoke(t, "listSnapshot", int64(epoch))
+	require.NoError(t, err)
+	require.Equal(t, 1, s.Len())
+	checkSnapshotIterator(t, s, nodes)
 }
 
 func checkSnapshot(t *testing.T, s *vm.Stack, nodes []testNodeInfo) {
@@ -306,6 +1081,30 @@ func checkSnapshot(t *testing.T, s *vm.Stack, nodes []testNodeInfo) {
 	require.ElementsMatch(t, expected, actual, "snapshot is different")
 }
 
+// checkSnapshotIterator checks snapshot entries returned by the iterator-based
+// methods, they contain the node state unlike the ones returned by the array
+// methods.
+func checkSnapshotIterator(t *testing.T, s *vm.Stack, nodes []testNodeInfo) {
+	arr := iteratorToArray(s.Pop().Value().(*storage.Iterator))
+	require.Equal(t, len(nodes), len(arr), "expected %d nodes", len(nodes))
+
+	actual := make([][]byte, len(nodes))
+	expected := make([][]byte, len(nodes))
+	for i := range nodes {
+		n, ok := arr[i].Value().([]stackitem.Item)
+		require.True(t, ok, "expected node struct")
+		require.Equal(t, 2, len(n), "expected info and state fields")
+
+		raw, ok := n[0].Value().([]byte)
+		require.True(t, ok, "expected bytes")
+
+		actual[i] = raw
+		expected[i] = nodes[i].raw
+	}
+
+	require.ElementsMatch(t, expected, actual, "snapshot is different")
+}
+
 func TestUpdateStateIR(t *testing.T) {
 	cNm := newNetmapInvoker(t)
 
@@ -324,10 +1123,72 @@ func TestUpdateStateIR(t *testing.T) {
 	})
 
 	checkNetmapCandidates(t, cNm, 1)
+	t.Run("maintenance", func(t *testing.T) {
+		cNm.Invoke(t, stackitem.Null{}, "updateStateIR", int64(netmap.MaintenanceState), pub)
+		checkNetmapCandidates(t, cNm, 1)
+		checkCandidateState(t, cNm, pub, int64(netmap.MaintenanceState))
+	})
 	t.Run("good", func(t *testing.T) {
 		cNm.Invoke(t, stackitem.Null{}, "updateStateIR", int64(2), pub)
 		checkNetmapCandidates(t, cNm, 0)
 	})
+	t.Run("maintenance of missing node", func(t *testing.T) {
+		cNm.InvokeFail(t, "peer is missing", "updateStateIR", int64(netmap.MaintenanceState), pub)
+	})
+}
+
+func TestUpdateStateMaintenance(t *testing.T) {
+	cNm := newNetmapInvoker(t)
+
+	acc := cNm.NewAccount(t)
+	dummyInfo := dummyNodeInfo(acc)
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", dummyInfo.raw)
+
+	cBoth := cNm.WithSigners(acc, cNm.Committee)
+	h := cBoth.Invoke(t, stackitem.Null{}, "updateState", int64(netmap.MaintenanceState), dummyInfo.pub)
+	aer := cBoth.CheckHalt(t, h)
+	require.Equal(t, 1, len(aer.Events))
+	require.Equal(t, "UpdateStateSuccess", aer.Events[0].Name)
+	checkNetmapCandidates(t, cNm, 1)
+	checkCandidateState(t, cNm, dummyInfo.pub, int64(netmap.MaintenanceState))
+
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+
+	s, err := cNm.TestInvoke(t, "netmap")
+	require.NoError(t, err)
+	checkSnapshot(t, s, []testNodeInfo{dummyInfo})
+
+	s, err = cNm.TestInvoke(t, "listNetmap")
+	require.NoError(t, err)
+	checkSnapshotIterator(t, s, []testNodeInfo{dummyInfo})
+
+	s, err = cNm.TestInvoke(t, "listNetmap")
+	require.NoError(t, err)
+	arr := iteratorToArray(s.Pop().Value().(*storage.Iterator))
+	require.Equal(t, 1, len(arr))
+	require.Equal(t, stackitem.Make(int64(netmap.MaintenanceState)), arr[0].Value().([]stackitem.Item)[1])
+
+	t.Run("back online", func(t *testing.T) {
+		cNm.Invoke(t, stackitem.Null{}, "addPeerIR", dummyInfo.raw)
+		checkCandidateState(t, cNm, dummyInfo.pub, int64(netmap.OnlineState))
+	})
+}
+
+func checkCandidateState(t *testing.T, c *neotest.ContractInvoker, pub []byte, st int64) {
+	s, err := c.TestInvoke(t, "netmapCandidates")
+	require.NoError(t, err)
+
+	arr, ok := s.Pop().Value().([]stackitem.Item)
+	require.True(t, ok)
+	for i := range arr {
+		n := arr[i].Value().([]stackitem.Item)
+		info := n[0].Value().([]stackitem.Item)[0].Value().([]byte)
+		if bytes.Equal(info[2:35], pub) {
+			require.Equal(t, stackitem.Make(st), n[1])
+			return
+		}
+	}
+	require.FailNow(t, "candidate is missing")
 }
 
 func TestUpdateState(t *testing.T) {
@@ -365,6 +1226,114 @@ func TestUpdateState(t *testing.T) {
 	checkNetmapCandidates(t, cNm, 0)
 }
 
+func TestRotateNodeKey(t *testing.T) {
+	cNm := newNetmapInvoker(t)
+
+	oldAcc, newAcc := cNm.NewAccount(t), cNm.NewAccount(t)
+	oldNode := dummyNodeInfo(oldAcc, "Continent", "Europe")
+	newNode := dummyNodeInfo(newAcc, "Continent", "Europe")
+
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", oldNode.raw)
+	cNm.Invoke(t, stackitem.Null{}, "newEpoch", int64(1))
+	cNm.Invoke(t, stackitem.Null{}, "updateStateIR", int64(netmap.MaintenanceState), oldNode.pub)
+
+	t.Run("missing witness", func(t *testing.T) {
+		cNm.WithSigners(oldAcc, cNm.Committee).InvokeFail(t, common.ErrWitnessFailed,
+			"rotateNodeKey", oldNode.pub, newNode.raw)
+		cNm.WithSigners(newAcc, cNm.Committee).InvokeFail(t, common.ErrWitnessFailed,
+			"rotateNodeKey", oldNode.pub, newNode.raw)
+		cNm.WithSigners(oldAcc, newAcc).InvokeFail(t, common.ErrAlphabetWitnessFailed,
+			"rotateNodeKey", oldNode.pub, newNode.raw)
+	})
+
+	cAll := cNm.WithSigners(oldAcc, newAcc, cNm.Committee)
+	cAll.InvokeFail(t, "peer is missing", "rotateNodeKey", newNode.pub, oldNode.raw)
+	cAll.InvokeFail(t, "same public key", "rotateNodeKey", oldNode.pub, oldNode.raw)
+
+	h := cAll.Invoke(t, stackitem.Null{}, "rotateNodeKey", oldNode.pub, newNode.raw)
+	aer := cNm.CheckHalt(t, h)
+	require.Equal(t, 1, len(aer.Events))
+	require.Equal(t, "RotateNodeKeySuccess", aer.Events[0].Name)
+	require.Equal(t, [][]byte{oldNode.pub, newNode.pub},
+		stackItemsToBytes(t, aer.Events[0].Item.Value().([]stackitem.Item)))
+
+	checkNetmapCandidates(t, cNm, 1)
+	checkCandidateState(t, cNm, newNode.pub, int64(netmap.MaintenanceState))
+	checkCandidatesByAttribute(t, cNm, "Continent", "Europe", newNode)
+
+	cNm.Invoke(t, stackitem.Null{}, "nodeStats", oldNode.pub)
+	checkNodeStats(t, cNm, newNode, 1, 1, 1)
+
+	cNm.Invoke(t, oldNode.pub, "previousNodeKey", newNode.pub)
+	cNm.Invoke(t, newNode.pub, "nextNodeKey", oldNode.pub)
+	cNm.Invoke(t, stackitem.Null{}, "previousNodeKey", oldNode.pub)
+	cNm.Invoke(t, stackitem.Null{}, "nextNodeKey", newNode.pub)
+
+	t.Run("rotated key can't be used again", func(t *testing.T) {
+		cAll.InvokeFail(t, "public key has already been rotated", "rotateNodeKey", newNode.pub, oldNode.raw)
+		cNm.InvokeFail(t, "public key has already been rotated", "addPeerIR", oldNode.raw)
+		cNm.WithSigners(oldAcc).InvokeFail(t, "public key has already been rotated", "addPeer", oldNode.raw)
+		checkNetmapCandidates(t, cNm, 1)
+	})
+}
+
+func TestAdmissionCheck(t *testing.T) {
+	cNm := newNetmapInvoker(t)
+
+	subnetHash := deploySubnetContract(t, cNm.Executor)
+	cSub := cNm.CommitteeInvoker(subnetHash)
+
+	owner := cSub.NewAccount(t)
+	ownerPub := owner.(neotest.SingleSigner).Account().PrivateKey().PublicKey().Bytes()
+	subnetID := []byte{0x0D, 1, 0, 0, 0} // stable marshaled refs.SubnetID with value 1
+	cSub.WithSigners(cSub.Committee, owner).Invoke(t, stackitem.Null{}, "put", subnetID, ownerPub, []byte{1})
+
+	allowed := dummyNodeInfo(cNm.NewAccount(t), "__NEOFS__SUBNET_1", "True")
+	denied := dummyNodeInfo(cNm.NewAccount(t), "__NEOFS__SUBNET_1", "True")
+	zeroSubnet := dummyNodeInfo(cNm.NewAccount(t), "__NEOFS__SUBNET_0", "True", "__NEOFS__SUBNET_1", "False")
+	cSub.WithSigners(owner).Invoke(t, stackitem.Null{}, "addNode", subnetID, allowed.pub)
+
+	// check is disabled by default
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", denied.raw)
+	cNm.Invoke(t, stackitem.Null{}, "updateStateIR", int64(netmap.OfflineState), denied.pub)
+
+	cNm.Invoke(t, stackitem.Null{}, "setConfig", []byte{1}, netmap.AdmissionCheckKey, true)
+	cNm.InvokeFail(t, "subnet contract is not set", "addPeerIR", denied.raw)
+
+	cNm.Invoke(t, stackitem.Null{}, "setConfig", []byte{1}, netmap.SubnetContractKey, subnetHash.BytesBE())
+	cNm.InvokeFail(t, netmap.ErrNodeNotAllowed, "addPeerIR", denied.raw)
+	cNm.WithSigners(denied.signer).InvokeFail(t, netmap.ErrNodeNotAllowed, "addPeer", denied.raw)
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", allowed.raw)
+	cNm.Invoke(t, stackitem.Null{}, "addPeerIR", zeroSubnet.raw)
+	checkNetmapCandidates(t, cNm, 2)
+
+	t.Run("invalid subnet attribute", func(t *testing.T) {
+		for _, key := range []string{"__NEOFS__SUBNET_abc", "__NEOFS__SUBNET_-1", "__NEOFS__SUBNET_4294967296"} {
+			invalid := dummyNodeInfo(cNm.NewAccount(t), key, "True")
+			cNm.InvokeFail(t, netmap.ErrInvalidNodeAttribute, "addPeerIR", invalid.raw)
+		}
+		checkNetmapCandidates(t, cNm, 2)
+	})
+
+	t.Run("allowlist", func(t *testing.T) {
+		cNm.Invoke(t, stackitem.Null{}, "setConfig", []byte{1}, netmap.AdmissionAllowlistKey, true)
+		cNm.InvokeFail(t, netmap.ErrNodeNotInAllowlist, "addPeerIR", allowed.raw)
+
+		cNm.Invoke(t, stackitem.Null{}, "addToAllowlist", allowed.pub)
+		cNm.Invoke(t, stackitem.Null{}, "addPeerIR", allowed.raw)
+
+		s, err := cNm.TestInvoke(t, "listAllowlist")
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{allowed.pub},
+			stackItemsToBytes(t, iteratorToArray(s.Pop().Value().(*storage.Iterator))))
+
+		cNm.InvokeFail(t, "incorrect public key", "removeFromAllowlist", allowed.pub[1:])
+		cNm.Invoke(t, stackitem.Null{}, "removeFromAllowlist", allowed.pub)
+		cNm.InvokeFail(t, netmap.ErrNodeNotInAllowlist, "addPeerIR", allowed.raw)
+		checkNetmapCandidates(t, cNm, 2)
+	})
+}
+
 func checkNetmapCandidates(t *testing.T, c *neotest.ContractInvoker, size int) {
 	s, err := c.TestInvoke(t, "netmapCandidates")
 	require.NoError(t, err)
@@ -373,4 +1342,17 @@ func checkNetmapCandidates(t *testing.T, c *neotest.ContractInvoker, size int) {
 	arr, ok := s.Pop().Value().([]stackitem.Item)
 	require.True(t, ok)
 	require.Equal(t, size, len(arr))
+
+	s, err = c.TestInvoke(t, "listCandidates")
+	require.NoError(t, err)
+	require.Equal(t, 1, s.Len())
+	require.Equal(t, size, len(iteratorToArray(s.Pop().Value().(*storage.Iterator))))
+}
+
+func iteratorToArray(iter *storage.Iterator) []stackitem.Item {
+	items := make([]stackitem.Item, 0)
+	for iter.Next() {
+		items = append(items, iter.Value())
+	}
+	return items
 }
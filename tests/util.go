@@ -3,6 +3,7 @@ package tests
 import (
 	"testing"
 
+	"github.com/nspcc-dev/neo-go/pkg/core/native/nativenames"
 	"github.com/nspcc-dev/neo-go/pkg/neotest"
 	"github.com/nspcc-dev/neo-go/pkg/neotest/chain"
 )
@@ -11,3 +12,16 @@ func newExecutor(t *testing.T) *neotest.Executor {
 	bc, acc := chain.NewSingle(t)
 	return neotest.NewExecutor(t, bc, acc, acc)
 }
+
+// newMultiExecutor returns an executor of the chain with several committee
+// members, so votes of different alphabet nodes can be collected in notary
+// disabled environment.
+func newMultiExecutor(t *testing.T) *neotest.Executor {
+	bc, validators, committee := chain.NewMulti(t)
+	e := neotest.NewExecutor(t, bc, validators, committee)
+
+	gas := e.CommitteeInvoker(e.NativeHash(t, nativenames.Gas)).WithSigners(e.Validator)
+	gas.Invoke(t, true, "transfer",
+		e.Validator.ScriptHash(), e.CommitteeHash, int64(1000_0000_0000), nil)
+	return e
+}